@@ -2,20 +2,20 @@ package main
 
 import (
 	"context"
-	"crypto/rsa"
 	"fmt"
 	"log"
+	"os"
 	"os/signal"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/JinFuuMugen/ya_go_metrics/internal/config"
-	"github.com/JinFuuMugen/ya_go_metrics/internal/cryptography/rsacrypto"
 	"github.com/JinFuuMugen/ya_go_metrics/internal/logger"
 	"github.com/JinFuuMugen/ya_go_metrics/internal/monitors"
-	"github.com/JinFuuMugen/ya_go_metrics/internal/sender"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/push"
 	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
 )
 
@@ -29,6 +29,13 @@ func main() {
 		log.Fatalf("cannot create config: %s", err)
 	}
 
+	if cfg.PrintConfig {
+		if err := config.Print(os.Stdout, cfg); err != nil {
+			log.Fatalf("cannot print config: %s", err)
+		}
+		return
+	}
+
 	err = logger.Init()
 	if err != nil {
 		log.Fatalf("cannot initialize logger: %s", err)
@@ -41,36 +48,64 @@ func main() {
 	)
 	defer stop()
 
-	pollTicker := cfg.PollTicker()
 	reportTicker := cfg.ReportTicker()
-	defer pollTicker.Stop()
 	defer reportTicker.Stop()
 
-	str := storage.NewStorage()
-
-	var publicKey *rsa.PublicKey
-	if cfg.CryptoKey != "" {
-		publicKey, err = rsacrypto.LoadPublicKey(cfg.CryptoKey)
+	var str storage.Storage = storage.NewStorage()
+	if len(cfg.AggregateGauges) > 0 {
+		str, err = monitors.NewAggregator(str, cfg.AggregateGauges, cfg.AggregateStats)
 		if err != nil {
-			log.Fatalf("cannot load public key: %s", err)
+			log.Fatalf("cannot create gauge aggregator: %s", err)
 		}
 	}
 
-	var snd sender.Sender
+	pl, err := newPipeline(cfg, str, nil)
+	if err != nil {
+		log.Fatalf("cannot create agent pipeline: %s", err)
+	}
+	pl.start(ctx)
 
-	if cfg.GRPCAddr != "" {
-		gs, err := sender.NewGRPCSender(*cfg)
-		if err != nil {
-			log.Fatalf("cannot init grpc sender: %s", err)
+	reporter := monitors.NewReporter(str, pl.sender)
+
+	reloads := make(chan struct{}, 1)
+	requestReload := func() {
+		select {
+		case reloads <- struct{}{}:
+		default:
 		}
-		defer gs.Close()
-		snd = gs
-	} else {
-		snd = sender.NewSender(*cfg, publicKey)
 	}
 
-	m := monitors.NewRuntimeMonitor(str, snd)
-	g := monitors.NewGopsutilMonitor(str, snd)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	go func() {
+		for {
+			select {
+			case <-hup:
+				requestReload()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	watchCtx, stopWatch := context.WithCancel(ctx)
+	watchConfig := func(cfg *config.AgentConfig) {
+		if cfg.ConfigPath == "" || cfg.ConfigWatchInterval <= 0 {
+			return
+		}
+		go config.WatchFile(watchCtx, cfg.ConfigPath, cfg.ConfigWatchInterval, requestReload)
+	}
+	watchConfig(cfg)
+
+	var pushSrv *push.Server
+	if cfg.PushAddr != "" || cfg.PushSocket != "" {
+		pushSrv, err = push.Listen(cfg.PushAddr, cfg.PushSocket, push.Handler(str))
+		if err != nil {
+			log.Fatalf("cannot start push endpoint: %s", err)
+		}
+		pushSrv.Serve()
+	}
 
 	rateLimit := cfg.RateLimit
 	semaphore := make(chan struct{}, rateLimit)
@@ -91,12 +126,77 @@ func main() {
 	}
 
 	finalFlush := func() {
-		if err := m.Dump(); err != nil {
-			logger.Warnf("final dump runtime metrics error: %s", err)
+		if err := reporter.Report(); err != nil {
+			logger.Warnf("final report error: %s", err)
+		}
+
+		flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := pl.close(flushCtx); err != nil {
+			logger.Warnf("final delivery error: %s", err)
+		}
+	}
+
+	swapped := make(chan struct{})
+	close(swapped)
+
+	reload := func() {
+		next, err := config.ReloadAgentConfig()
+		if err != nil {
+			logger.Errorf("config reload rejected, keeping current config: %s", err)
+			return
 		}
-		if err := g.Dump(); err != nil {
-			logger.Warnf("final dump gopsutil metrics error: %s", err)
+
+		for _, change := range restartOnlyChanges(cfg, next) {
+			logger.Warnf("config reload: %s changed, restart the agent to apply it", change)
+		}
+		next.RateLimit = cfg.RateLimit
+		next.AggregateGauges, next.AggregateStats = cfg.AggregateGauges, cfg.AggregateStats
+		next.PushAddr, next.PushSocket = cfg.PushAddr, cfg.PushSocket
+
+		nextPl, err := newPipeline(next, str, pl)
+		if err != nil {
+			logger.Errorf("config reload rejected, keeping current config: %s", err)
+			return
 		}
+
+		prev := pl
+		prev.stop()
+		prev.wait()
+
+		nextPl.start(ctx)
+		pl = nextPl
+
+		reportTicker.Reset(next.ReportInterval)
+
+		stopWatch()
+		watchCtx, stopWatch = context.WithCancel(ctx)
+		watchConfig(next)
+
+		cfg = next
+
+		// A report in progress may be retrying against the replaced senders,
+		// so the swap waits for it without blocking the loop. Swaps are
+		// chained to apply in the order of the reloads.
+		prevSwap, swap := swapped, make(chan struct{})
+		swapped = swap
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-prevSwap
+			reporter.SetSender(nextPl.sender)
+			close(swap)
+
+			closeCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := prev.close(closeCtx); err != nil {
+				logger.Warnf("delivery error of replaced senders: %s", err)
+			}
+		}()
+
+		logger.Infof("config reloaded: poll interval %s, report interval %s, %d collectors",
+			next.PollInterval, next.ReportInterval, len(nextPl.collectors))
 	}
 
 	for {
@@ -109,28 +209,31 @@ func main() {
 
 			logger.Infof("shutdown signal received, stopping agent...")
 
-			pollTicker.Stop()
 			reportTicker.Stop()
+			stopWatch()
+			pl.stop()
+
+			if pushSrv != nil {
+				shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				if err := pushSrv.Shutdown(shutdownCtx); err != nil {
+					logger.Warnf("push endpoint shutdown error: %s", err)
+				}
+				cancel()
+			}
 
 			wg.Wait()
+			pl.wait()
 
 			finalFlush()
 
 			logger.Infof("agent stopped gracefully")
 			return
 
-		case <-pollTicker.C:
+		case <-reloads:
 			if shuttingDown.Load() {
 				continue
 			}
-			if !waitRateToken() {
-				continue
-			}
-
-			m.CollectRuntimeMetrics()
-			if err := g.CollectGopsutil(); err != nil {
-				logger.Fatalf("error collecting gopsutil metrics: %s", err)
-			}
+			reload()
 
 		case <-reportTicker.C:
 			if shuttingDown.Load() {
@@ -149,17 +252,29 @@ func main() {
 						<-semaphore
 					}()
 
-					if err := m.Dump(); err != nil {
-						logger.Warnf("error dumping runtime metrics: %s", err)
-					}
-
-					if err := g.Dump(); err != nil {
-						logger.Warnf("error dumping gopsutil metrics: %s", err)
+					if err := reporter.Report(); err != nil {
+						logger.Warnf("error reporting metrics: %s", err)
 					}
 				}()
 			default:
-				logger.Warnf("maximum concurrent Dump executions reached, skipping current dump")
+				logger.Warnf("maximum concurrent reports reached, skipping current report")
 			}
 		}
 	}
 }
+
+// restartOnlyChanges lists the settings that differ between cur and next but
+// are only applied when the agent starts.
+func restartOnlyChanges(cur, next *config.AgentConfig) []string {
+	var changes []string
+	if cur.RateLimit != next.RateLimit {
+		changes = append(changes, "rate limit")
+	}
+	if !slices.Equal(cur.AggregateGauges, next.AggregateGauges) || !slices.Equal(cur.AggregateStats, next.AggregateStats) {
+		changes = append(changes, "gauge aggregation")
+	}
+	if cur.PushAddr != next.PushAddr || cur.PushSocket != next.PushSocket {
+		changes = append(changes, "push endpoint")
+	}
+	return changes
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"crypto/rsa"
+	"fmt"
+	"path/filepath"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/config"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/cryptography/rsacrypto"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/fanout"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/monitors"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/relabel"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/sender"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/spool"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
+)
+
+// pipeline holds the parts of the agent built from its configuration:
+// the collectors and the sender chain. A reload builds a new pipeline
+// next to the running one and only swaps them once it is complete.
+type pipeline struct {
+	cfg        *config.AgentConfig
+	sender     sender.Sender
+	fan        *fanout.Sender
+	collectors []monitors.Collector
+	closers    []func()
+
+	// queues holds the spool queues by directory. They are shared with the
+	// pipeline built by the next reload, so a directory is never used by two
+	// queues while the replaced senders are still draining it.
+	queues map[string]*spool.Queue
+	limits []func()
+
+	stop func()
+	wait func()
+}
+
+// newPipeline builds the collectors writing into str and the senders
+// described by cfg. Nothing is started, and on error the senders built
+// so far are released. prev is the pipeline being replaced, if any: its
+// spool queues and the state of its unchanged collectors are taken over.
+func newPipeline(cfg *config.AgentConfig, str storage.Storage, prev *pipeline) (_ *pipeline, err error) {
+	p := &pipeline{cfg: cfg, queues: make(map[string]*spool.Queue), stop: func() {}, wait: func() {}}
+	defer func() {
+		if err != nil {
+			p.release()
+		}
+	}()
+
+	var prevCollectors []monitors.Collector
+	if prev != nil {
+		prevCollectors = prev.collectors
+		for dir, q := range prev.queues {
+			p.queues[dir] = q
+		}
+	}
+
+	// Limits of reused queues only change once the whole pipeline is built,
+	// so a rejected reload leaves the running queues alone.
+	defer func() {
+		if err == nil {
+			for _, apply := range p.limits {
+				apply()
+			}
+		}
+		p.limits = nil
+	}()
+
+	p.collectors, err = monitors.Rebuild(str, cfg, prevCollectors)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create collectors: %w", err)
+	}
+
+	var rules *relabel.Pipeline
+	if len(cfg.Relabel) > 0 {
+		rules, err = relabel.New(cfg.Relabel)
+		if err != nil {
+			return nil, fmt.Errorf("invalid relabel rules: %w", err)
+		}
+	}
+
+	if len(cfg.Destinations) > 0 {
+		dests := make([]fanout.Destination, 0, len(cfg.Destinations))
+		for _, d := range cfg.Destinations {
+			ds, closeSender, err := p.newSender(cfg.ForDestination(d))
+			if err != nil {
+				return nil, fmt.Errorf("cannot init sender for destination %s: %w", d.Name, err)
+			}
+			p.closers = append(p.closers, closeSender)
+			dests = append(dests, fanout.Destination{Name: d.Name, Sender: ds})
+		}
+		p.fan = fanout.New(dests, fanout.DefaultMaxPending)
+		p.sender = p.fan
+	} else {
+		snd, closeSender, err := p.newSender(*cfg)
+		if err != nil {
+			return nil, fmt.Errorf("cannot init sender: %w", err)
+		}
+		p.closers = append(p.closers, closeSender)
+		p.sender = snd
+	}
+
+	if rules != nil {
+		p.sender = sender.NewRelabelSender(p.sender, rules)
+	}
+
+	return p, nil
+}
+
+// start runs the collectors until ctx is done or stop is called.
+func (p *pipeline) start(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	p.stop = cancel
+	p.wait = monitors.Run(ctx, p.collectors)
+}
+
+// close makes one last delivery attempt of the queued reports until ctx is
+// done and releases the connections of the senders. The collectors must be
+// stopped and the senders no longer used by the reporter.
+func (p *pipeline) close(ctx context.Context) error {
+	var err error
+	if p.fan != nil {
+		err = p.fan.Close(ctx)
+	}
+	p.release()
+	return err
+}
+
+func (p *pipeline) release() {
+	for _, c := range p.closers {
+		c()
+	}
+	p.closers = nil
+}
+
+// newSender creates the sender of the single destination described by cfg,
+// including its spool. The returned function releases its connections.
+func (p *pipeline) newSender(cfg config.AgentConfig) (sender.Sender, func(), error) {
+	var snd sender.Sender
+	closeSender := func() {}
+
+	var publicKey *rsa.PublicKey
+	if cfg.CryptoKey != "" {
+		var err error
+		publicKey, err = rsacrypto.LoadPublicKey(cfg.CryptoKey)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot load public key: %w", err)
+		}
+	}
+
+	if cfg.GRPCAddr != "" {
+		gs, err := sender.NewGRPCSender(cfg, publicKey)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot init grpc sender: %w", err)
+		}
+		closeSender = func() { gs.Close() }
+		snd = gs
+	} else {
+		hs := sender.NewSender(cfg, publicKey)
+		closeSender = func() { hs.Close() }
+		snd = hs
+	}
+
+	if cfg.SpoolDir != "" {
+		queue, err := p.queue(cfg)
+		if err != nil {
+			closeSender()
+			return nil, nil, err
+		}
+		snd = spool.NewSender(snd, queue)
+	}
+
+	return snd, closeSender, nil
+}
+
+// queue returns the spool queue of cfg, reusing the one already open for its directory.
+func (p *pipeline) queue(cfg config.AgentConfig) (*spool.Queue, error) {
+	dir := filepath.Clean(cfg.SpoolDir)
+	if q, ok := p.queues[dir]; ok {
+		p.limits = append(p.limits, func() { q.SetLimits(cfg.SpoolMaxBytes, cfg.SpoolMaxAge) })
+		return q, nil
+	}
+
+	q, err := spool.Open(dir, cfg.SpoolMaxBytes, cfg.SpoolMaxAge)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open spool: %w", err)
+	}
+	p.queues[dir] = q
+	return q, nil
+}
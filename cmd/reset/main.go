@@ -134,6 +134,9 @@ func generateResetMethod(st StructInfo) string {
 	b.WriteString(fmt.Sprintf("\tif %s == nil {\n\t\treturn\n\t}\n", r))
 
 	for _, f := range st.Fields {
+		if isLock(f.Type) {
+			continue
+		}
 		for _, n := range f.Names {
 			fieldName := r + "." + n.Name
 			b.WriteString(resetLine(fieldName, f.Type))
@@ -182,6 +185,15 @@ func pointerResetLine(name string, tt *ast.StarExpr) string {
 	return b.String()
 }
 
+// isLock reports whether t is a sync.Mutex or sync.RWMutex, which must not be reassigned.
+func isLock(t ast.Expr) bool {
+	switch exprString(t) {
+	case "sync.Mutex", "sync.RWMutex":
+		return true
+	}
+	return false
+}
+
 func isPrimitive(name string) bool {
 	switch name {
 	case "int", "int8", "int16", "int32", "int64",
@@ -8,11 +8,11 @@ import (
 	"net"
 	"net/http"
 	_ "net/http/pprof"
+	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
-	"github.com/JinFuuMugen/ya_go_metrics/internal/audit"
 	"github.com/JinFuuMugen/ya_go_metrics/internal/compress"
 	"github.com/JinFuuMugen/ya_go_metrics/internal/config"
 	"github.com/JinFuuMugen/ya_go_metrics/internal/cryptography"
@@ -20,6 +20,7 @@ import (
 	"github.com/JinFuuMugen/ya_go_metrics/internal/database"
 	"github.com/JinFuuMugen/ya_go_metrics/internal/grpcmetrics"
 	"github.com/JinFuuMugen/ya_go_metrics/internal/handlers"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/idempotency"
 	"github.com/JinFuuMugen/ya_go_metrics/internal/io"
 	"github.com/JinFuuMugen/ya_go_metrics/internal/logger"
 	"github.com/JinFuuMugen/ya_go_metrics/internal/network"
@@ -39,22 +40,20 @@ func main() {
 		log.Fatalf("cannot create config: %s", err)
 	}
 
-	if err := logger.Init(); err != nil {
-		log.Fatalf("cannot create logger: %s", err)
+	if cfg.PrintConfig {
+		if err := config.Print(os.Stdout, cfg); err != nil {
+			log.Fatalf("cannot print config: %s", err)
+		}
+		return
 	}
 
-	publisher := audit.NewPublisher()
-
-	if cfg.AuditFile != "" {
-		fo, err := audit.NewFileObserver(cfg.AuditFile)
-		if err != nil {
-			log.Fatal(err)
-		}
-		publisher.Subscribe(fo)
+	if err := logger.Init(); err != nil {
+		log.Fatalf("cannot create logger: %s", err)
 	}
 
-	if cfg.AuditURL != "" {
-		publisher.Subscribe(audit.NewHTTPObserver(cfg.AuditURL))
+	state, err := newLiveState(cfg)
+	if err != nil {
+		log.Fatal(err)
 	}
 
 	var db *database.Database
@@ -69,7 +68,8 @@ func main() {
 		}
 	}
 
-	if err := io.Run(cfg, db); err != nil {
+	state.dumper, err = io.Run(cfg, db)
+	if err != nil {
 		log.Fatalf("cannot load preload metrics: %s", err)
 	}
 
@@ -77,14 +77,7 @@ func main() {
 
 	rout := chi.NewRouter()
 
-	if cfg.CryptoKey != "" {
-		privateKey, err := rsacrypto.LoadPrivateKey(cfg.CryptoKey)
-		if err != nil {
-			log.Fatalf("cannot load private key: %s", err)
-		}
-
-		rout.Use(rsacrypto.CryptoMiddleware(privateKey))
-	}
+	rout.Use(rsacrypto.DecryptMiddleware(state.decrypter))
 
 	tmpl, err := template.ParseFiles("internal/static/index.html")
 	if err != nil {
@@ -100,19 +93,22 @@ func main() {
 
 	rout.Get("/ping", handlers.PingDBHandler(db))
 
+	idempotencyCache := idempotency.NewCache(cfg.IdempotencyWindow)
+
 	rout.Route("/updates", func(r chi.Router) {
-		r.Use(network.CheckValidSubnetMiddleware(cfg.TrustedSubnet))
-		r.Use(cryptography.ValidateHashMiddleware(cfg))
-		r.Use(io.GetDumperMiddleware(cfg, db))
-		r.Post("/", handlers.UpdateBatchMetricsHandler(st, publisher))
+		r.Use(network.TrustedSubnetMiddleware(state.subnet))
+		r.Use(cryptography.ValidateHashMiddleware(state.hashKey))
+		r.Use(idempotency.Middleware(idempotencyCache))
+		r.Use(io.GetDumperMiddleware(state.dumper))
+		r.Post("/", handlers.UpdateBatchMetricsHandler(st, state.publisher))
 	})
 
 	rout.Route("/update", func(r chi.Router) {
-		r.Use(network.CheckValidSubnetMiddleware(cfg.TrustedSubnet))
-		r.Use(io.GetDumperMiddleware(cfg, db))
-		r.Use(cryptography.ValidateHashMiddleware(cfg))
-		r.Post("/", handlers.UpdateMetricsHandler(st, publisher))
-		r.Post("/{metric_type}/{metric_name}/{metric_value}", handlers.UpdateMetricsPlainHandler(st, publisher))
+		r.Use(network.TrustedSubnetMiddleware(state.subnet))
+		r.Use(io.GetDumperMiddleware(state.dumper))
+		r.Use(cryptography.ValidateHashMiddleware(state.hashKey))
+		r.Post("/", handlers.UpdateMetricsHandler(st, state.publisher))
+		r.Post("/{metric_type}/{metric_name}/{metric_value}", handlers.UpdateMetricsPlainHandler(st, state.publisher))
 	})
 
 	rout.Post("/value/", handlers.GetMetricHandler(st))
@@ -131,10 +127,14 @@ func main() {
 	}
 
 	grpcSrv := grpc.NewServer(
-		grpc.UnaryInterceptor(network.SubnetUnaryInterceptor(cfg.TrustedSubnet)),
+		grpc.ChainUnaryInterceptor(
+			network.TrustedSubnetUnaryInterceptor(state.subnet),
+			rsacrypto.DecryptUnaryInterceptor(state.decrypter),
+			idempotency.UnaryServerInterceptor(idempotencyCache),
+		),
 	)
 
-	pb.RegisterMetricsServer(grpcSrv, grpcmetrics.New(st, publisher))
+	pb.RegisterMetricsServer(grpcSrv, grpcmetrics.New(st, state.publisher))
 
 	grpcErrCh := make(chan error, 1)
 	go func() {
@@ -160,16 +160,39 @@ func main() {
 		close(errCh)
 	}()
 
-	select {
-	case <-ctx.Done():
-		logger.Infof("shutdown signal received")
-	case err := <-errCh:
-		if err != nil {
-			logger.Fatalf("cannot start server: %s", err)
-		}
-	case err := <-grpcErrCh:
-		if err != nil {
-			logger.Fatalf("cannot start grpc server: %s", err)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	current := cfg
+
+serve:
+	for {
+		select {
+		case <-hup:
+			next, err := config.ReloadServerConfig()
+			if err == nil {
+				next, err = state.reload(current, next)
+			}
+			if err != nil {
+				logger.Errorf("config reload rejected, keeping current config: %s", err)
+				continue
+			}
+			current = next
+			logger.Infof("config reloaded")
+		case <-ctx.Done():
+			logger.Infof("shutdown signal received")
+			break serve
+		case err := <-errCh:
+			if err != nil {
+				logger.Fatalf("cannot start server: %s", err)
+			}
+			break serve
+		case err := <-grpcErrCh:
+			if err != nil {
+				logger.Fatalf("cannot start grpc server: %s", err)
+			}
+			break serve
 		}
 	}
 
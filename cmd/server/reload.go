@@ -0,0 +1,172 @@
+package main
+
+import (
+	"crypto/rsa"
+	"fmt"
+	stdio "io"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/audit"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/config"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/cryptography"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/cryptography/rsacrypto"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/io"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/logger"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/network"
+)
+
+// liveState holds the server settings read by middlewares on every request.
+// Each of them is swapped atomically on reload while the listeners keep running.
+type liveState struct {
+	subnet    *network.TrustedSubnet
+	hashKey   *cryptography.HashKey
+	decrypter *rsacrypto.Decrypter
+	publisher *audit.Publisher
+	dumper    *io.Dumper
+}
+
+// newLiveState creates the live state for cfg. The dumper is set separately
+// once metrics are restored.
+func newLiveState(cfg *config.ServerConfig) (*liveState, error) {
+	subnet, err := network.NewTrustedSubnet(cfg.TrustedSubnet)
+	if err != nil {
+		return nil, err
+	}
+
+	priv, err := loadPrivateKey(cfg.CryptoKey)
+	if err != nil {
+		return nil, err
+	}
+
+	observers, err := newAuditObservers(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	publisher := audit.NewPublisher()
+	publisher.Replace(observers...)
+
+	return &liveState{
+		subnet:    subnet,
+		hashKey:   cryptography.NewHashKey(cfg.Key),
+		decrypter: rsacrypto.NewDecrypter(priv),
+		publisher: publisher,
+	}, nil
+}
+
+// reload applies the reloadable settings of next on top of cur and returns
+// the configuration now in effect. Everything that can fail is prepared
+// first, so on error nothing is changed. Settings only read at startup are
+// kept from cur with a warning.
+func (s *liveState) reload(cur, next *config.ServerConfig) (*config.ServerConfig, error) {
+	priv, err := loadPrivateKey(next.CryptoKey)
+	if err != nil {
+		return nil, err
+	}
+
+	auditChanged := cur.AuditFile != next.AuditFile || cur.AuditURL != next.AuditURL
+	var observers []audit.Observer
+	if auditChanged {
+		observers, err = newAuditObservers(next)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.subnet.Set(next.TrustedSubnet); err != nil {
+		closeAuditObservers(observers)
+		return nil, err
+	}
+	if cur.TrustedSubnet != next.TrustedSubnet {
+		logger.Infof("config reload: trusted subnet changed from %q to %q", cur.TrustedSubnet, next.TrustedSubnet)
+	}
+
+	s.hashKey.Set(next.Key)
+	if cur.Key != next.Key {
+		logger.Infof("config reload: HMAC key changed")
+	}
+
+	s.decrypter.SetKey(priv)
+	switch {
+	case cur.CryptoKey != next.CryptoKey:
+		logger.Infof("config reload: private key changed from %q to %q", cur.CryptoKey, next.CryptoKey)
+	case next.CryptoKey != "":
+		logger.Infof("config reload: private key reloaded from %q", next.CryptoKey)
+	}
+
+	if auditChanged {
+		closeAuditObservers(s.publisher.Replace(observers...))
+		logger.Infof("config reload: audit destinations changed from file %q, url %q to file %q, url %q",
+			cur.AuditFile, cur.AuditURL, next.AuditFile, next.AuditURL)
+	}
+
+	if s.dumper != nil {
+		s.dumper.SetStoreInterval(next.StoreInterval)
+	}
+	if cur.StoreInterval != next.StoreInterval {
+		logger.Infof("config reload: store interval changed from %s to %s", cur.StoreInterval, next.StoreInterval)
+	}
+
+	applied := *next
+	keepStartupSettings(cur, &applied)
+	return &applied, nil
+}
+
+// keepStartupSettings copies the settings only read at startup from cur to
+// next, warning about every one that was changed.
+func keepStartupSettings(cur, next *config.ServerConfig) {
+	warn := func(name string, changed bool) {
+		if changed {
+			logger.Warnf("config reload: %s changed, restart the server to apply it", name)
+		}
+	}
+
+	warn("address", cur.Addr != next.Addr)
+	warn("gRPC address", cur.GRPCAddr != next.GRPCAddr)
+	warn("file storage path", cur.FileStoragePath != next.FileStoragePath)
+	warn("restore", cur.Restore != next.Restore)
+	warn("database DSN", cur.DatabaseDSN != next.DatabaseDSN)
+	warn("idempotency window", cur.IdempotencyWindow != next.IdempotencyWindow)
+
+	next.Addr, next.GRPCAddr = cur.Addr, cur.GRPCAddr
+	next.FileStoragePath, next.Restore, next.DatabaseDSN = cur.FileStoragePath, cur.Restore, cur.DatabaseDSN
+	next.IdempotencyWindow = cur.IdempotencyWindow
+}
+
+func loadPrivateKey(path string) (*rsa.PrivateKey, error) {
+	if path == "" {
+		return nil, nil
+	}
+	priv, err := rsacrypto.LoadPrivateKey(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load private key: %w", err)
+	}
+	return priv, nil
+}
+
+func newAuditObservers(cfg *config.ServerConfig) ([]audit.Observer, error) {
+	var observers []audit.Observer
+
+	if cfg.AuditFile != "" {
+		fo, err := audit.NewFileObserver(cfg.AuditFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create audit file observer: %w", err)
+		}
+		observers = append(observers, fo)
+	}
+
+	if cfg.AuditURL != "" {
+		observers = append(observers, audit.NewHTTPObserver(cfg.AuditURL))
+	}
+
+	return observers, nil
+}
+
+func closeAuditObservers(observers []audit.Observer) {
+	for _, o := range observers {
+		if c, ok := o.(stdio.Closer); ok {
+			if err := c.Close(); err != nil {
+				logger.Warnf("cannot close audit observer: %s", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/config"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/cryptography/rsacrypto"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/logger"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+func writePrivateKey(t *testing.T) (string, *rsa.PrivateKey) {
+	t.Helper()
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "private.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)})
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path, priv
+}
+
+func newTestState(t *testing.T) (*liveState, *config.ServerConfig, *rsa.PrivateKey) {
+	t.Helper()
+	_ = logger.Init()
+
+	keyPath, priv := writePrivateKey(t)
+	cur := &config.ServerConfig{
+		TrustedSubnet: "10.0.0.0/8",
+		Key:           "old-key",
+		CryptoKey:     keyPath,
+		AuditFile:     filepath.Join(t.TempDir(), "audit.log"),
+	}
+
+	s, err := newLiveState(cur)
+	require.NoError(t, err)
+	t.Cleanup(func() { closeAuditObservers(s.publisher.Replace()) })
+	return s, cur, priv
+}
+
+// requireUnchanged checks that s still applies the settings of cur.
+func requireUnchanged(t *testing.T, s *liveState, cur *config.ServerConfig, priv *rsa.PrivateKey) {
+	t.Helper()
+
+	require.Equal(t, cur.TrustedSubnet, s.subnet.String())
+	require.Equal(t, cur.Key, s.hashKey.Get())
+
+	envelope, err := rsacrypto.Encrypt(&priv.PublicKey, []byte("payload"))
+	require.NoError(t, err)
+	plain, err := s.decrypter.Decrypt(envelope)
+	require.NoError(t, err)
+	require.Equal(t, "payload", string(plain))
+
+	s.publisher.Publish(models.AuditEvent{Metrics: []string{"Probe"}})
+	data, err := os.ReadFile(cur.AuditFile)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "Probe")
+}
+
+func TestLiveStateReload_RejectedSubnetChangesNothing(t *testing.T) {
+	s, cur, priv := newTestState(t)
+
+	next := *cur
+	next.TrustedSubnet = "10.0.0.0/99"
+	next.Key = "new-key"
+	next.AuditFile = filepath.Join(t.TempDir(), "new-audit.log")
+
+	_, err := s.reload(cur, &next)
+	require.Error(t, err)
+	requireUnchanged(t, s, cur, priv)
+}
+
+func TestLiveStateReload_RejectedKeyChangesNothing(t *testing.T) {
+	s, cur, priv := newTestState(t)
+
+	next := *cur
+	next.TrustedSubnet = "192.168.0.0/16"
+	next.Key = "new-key"
+	next.CryptoKey = filepath.Join(t.TempDir(), "missing.pem")
+
+	_, err := s.reload(cur, &next)
+	require.Error(t, err)
+	requireUnchanged(t, s, cur, priv)
+}
+
+func TestLiveStateReload_SwapsAudit(t *testing.T) {
+	s, cur, _ := newTestState(t)
+
+	next := *cur
+	next.AuditFile = filepath.Join(t.TempDir(), "new-audit.log")
+
+	applied, err := s.reload(cur, &next)
+	require.NoError(t, err)
+	require.Equal(t, next.AuditFile, applied.AuditFile)
+
+	s.publisher.Publish(models.AuditEvent{Metrics: []string{"AfterReload"}})
+
+	data, err := os.ReadFile(next.AuditFile)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "AfterReload")
+
+	data, err = os.ReadFile(cur.AuditFile)
+	require.NoError(t, err)
+	require.False(t, strings.Contains(string(data), "AfterReload"), "the replaced audit file gets no more events")
+}
@@ -38,3 +38,8 @@ func (fo *FileObserver) Notify(auditEvent models.AuditEvent) error {
 
 	return nil
 }
+
+// Close closes the underlying file.
+func (fo *FileObserver) Close() error {
+	return fo.file.Close()
+}
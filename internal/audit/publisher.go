@@ -2,6 +2,7 @@ package audit
 
 import (
 	"reflect"
+	"sync"
 
 	"github.com/JinFuuMugen/ya_go_metrics/internal/logger"
 	"github.com/JinFuuMugen/ya_go_metrics/internal/models"
@@ -12,22 +13,54 @@ import (
 //generate:reset
 //go:generate go run ../../cmd/reset/main.go
 type Publisher struct {
+	mu        sync.Mutex
 	observers []Observer
+	inFlight  *inFlight
+}
+
+// inFlight tracks the events being sent to one set of Observers.
+type inFlight struct {
+	sync.WaitGroup
 }
 
 // NewPublisher creates new Publisher instance without subscribers.
 func NewPublisher() *Publisher {
-	return &Publisher{}
+	return &Publisher{inFlight: &inFlight{}}
 }
 
 // Subscribe registers an Observer to receive events.
 func (p *Publisher) Subscribe(o Observer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	p.observers = append(p.observers, o)
 }
 
+// Replace swaps all registered Observers for the given ones and returns the previous Observers.
+// It waits for events being sent to the previous Observers, so they may be closed once it returns.
+// Events published after Replace returns are only sent to the new Observers.
+func (p *Publisher) Replace(observers ...Observer) []Observer {
+	p.mu.Lock()
+	prev, sending := p.observers, p.inFlight
+	p.observers = observers
+	p.inFlight = &inFlight{}
+	p.mu.Unlock()
+
+	sending.Wait()
+	return prev
+}
+
 // Publish sends given AuditEvent to subscribers.
+// The Observers are notified outside the lock, so a slow Observer never blocks
+// other events or a Replace from taking the new Observers.
 func (p *Publisher) Publish(auditEvent models.AuditEvent) {
-	for _, o := range p.observers {
+	p.mu.Lock()
+	observers, sending := p.observers, p.inFlight
+	sending.Add(1)
+	p.mu.Unlock()
+	defer sending.Done()
+
+	for _, o := range observers {
 		err := o.Notify(auditEvent)
 		if err != nil {
 			logger.Errorf("cannot send audit event %s to %s : %w", auditEvent, reflect.TypeOf(o), err)
@@ -0,0 +1,89 @@
+package audit
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+type blockingObserver struct {
+	once    sync.Once
+	started chan struct{}
+	release chan struct{}
+}
+
+func (o *blockingObserver) Notify(models.AuditEvent) error {
+	o.once.Do(func() { close(o.started) })
+	<-o.release
+	return nil
+}
+
+type countingObserver struct {
+	mu     sync.Mutex
+	events int
+}
+
+func (o *countingObserver) Notify(models.AuditEvent) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.events++
+	return nil
+}
+
+func TestPublisher_ReplaceWaitsForInFlightPublish(t *testing.T) {
+	obs := &blockingObserver{started: make(chan struct{}), release: make(chan struct{})}
+
+	p := NewPublisher()
+	p.Subscribe(obs)
+
+	go p.Publish(models.AuditEvent{})
+	<-obs.started
+
+	replaced := make(chan []Observer)
+	go func() { replaced <- p.Replace() }()
+
+	select {
+	case <-replaced:
+		t.Fatal("Replace returned while the previous observer was still notified")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(obs.release)
+	require.Equal(t, []Observer{obs}, <-replaced)
+}
+
+func TestPublisher_PublishDoesNotWaitForReplace(t *testing.T) {
+	slow := &blockingObserver{started: make(chan struct{}), release: make(chan struct{})}
+	defer close(slow.release)
+
+	p := NewPublisher()
+	p.Subscribe(slow)
+
+	go p.Publish(models.AuditEvent{})
+	<-slow.started
+
+	next := &countingObserver{}
+	go p.Replace(next)
+
+	require.Eventually(t, func() bool {
+		done := make(chan struct{})
+		go func() {
+			p.Publish(models.AuditEvent{})
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			return false
+		}
+
+		next.mu.Lock()
+		defer next.mu.Unlock()
+		return next.events > 0
+	}, 5*time.Second, 10*time.Millisecond, "events are sent to the new observers while the slow one is still notified")
+}
@@ -8,5 +8,8 @@ func (p *Publisher) Reset() {
 	if p.observers != nil {
 		p.observers = p.observers[:0]
 	}
+	if p.inFlight != nil {
+		*p.inFlight = inFlight{}
+	}
 }
 
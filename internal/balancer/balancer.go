@@ -0,0 +1,184 @@
+// Package balancer spreads agent reports across server replicas.
+package balancer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/logger"
+)
+
+const dialTimeout = 2 * time.Second
+
+// ErrNoAddrs is returned when a pool is created without addresses.
+var ErrNoAddrs = errors.New("no server addresses")
+
+type target struct {
+	addr    string
+	healthy bool
+}
+
+// Pool picks server addresses round-robin, skipping replicas that failed
+// until a health check finds them reachable again.
+type Pool struct {
+	mu      sync.Mutex
+	targets []*target
+	next    int
+
+	check func(ctx context.Context, addr string) error
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// SplitAddrs splits a comma separated address list, dropping empty entries.
+func SplitAddrs(s string) []string {
+	var addrs []string
+	for _, a := range strings.Split(s, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			addrs = append(addrs, a)
+		}
+	}
+	return addrs
+}
+
+// New creates a pool of the given addresses, all considered healthy.
+func New(addrs []string) (*Pool, error) {
+	if len(addrs) == 0 {
+		return nil, ErrNoAddrs
+	}
+
+	p := &Pool{check: dialCheck}
+	for _, a := range addrs {
+		p.targets = append(p.targets, &target{addr: a, healthy: true})
+	}
+	return p, nil
+}
+
+// Addrs returns all addresses of the pool.
+func (p *Pool) Addrs() []string {
+	addrs := make([]string, len(p.targets))
+	for i, t := range p.targets {
+		addrs[i] = t.addr
+	}
+	return addrs
+}
+
+// Pick returns the next healthy address. If every replica is down it keeps
+// rotating over all of them, so a recovered server is found by the next report.
+func (p *Pool) Pick() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	n := len(p.targets)
+	for i := 0; i < n; i++ {
+		t := p.targets[(p.next+i)%n]
+		if t.healthy {
+			p.next = (p.next + i + 1) % n
+			return t.addr
+		}
+	}
+
+	t := p.targets[p.next]
+	p.next = (p.next + 1) % n
+	return t.addr
+}
+
+// Report records the outcome of a request to addr. A failed replica is
+// skipped by Pick until a request or health check succeeds again.
+func (p *Pool) Report(addr string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, t := range p.targets {
+		if t.addr != addr {
+			continue
+		}
+		if err != nil && t.healthy {
+			logger.Warnf("server %s marked unhealthy: %s", addr, err)
+		}
+		if err == nil && !t.healthy {
+			logger.Infof("server %s is healthy again", addr)
+		}
+		t.healthy = err == nil
+	}
+}
+
+// Healthy returns the addresses currently considered healthy.
+func (p *Pool) Healthy() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var addrs []string
+	for _, t := range p.targets {
+		if t.healthy {
+			addrs = append(addrs, t.addr)
+		}
+	}
+	return addrs
+}
+
+// Start health-checks every replica on the given interval until Stop.
+// Pools with a single address are not checked: there is nothing to fail over to.
+func (p *Pool) Start(interval time.Duration) {
+	if interval <= 0 || len(p.targets) < 2 {
+		return
+	}
+
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+
+	go func() {
+		defer close(p.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				p.CheckAll()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the health checks.
+func (p *Pool) Stop() {
+	if p.stop == nil {
+		return
+	}
+	close(p.stop)
+	<-p.done
+	p.stop = nil
+}
+
+// CheckAll health-checks every replica once.
+func (p *Pool) CheckAll() {
+	var wg sync.WaitGroup
+	for _, addr := range p.Addrs() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+			defer cancel()
+			p.Report(addr, p.check(ctx, addr))
+		}()
+	}
+	wg.Wait()
+}
+
+func dialCheck(ctx context.Context, addr string) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
@@ -0,0 +1,62 @@
+package balancer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/logger"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitAddrs(t *testing.T) {
+	require.Equal(t, []string{"a:1", "b:2"}, SplitAddrs(" a:1, ,b:2,"))
+	require.Nil(t, SplitAddrs(""))
+
+	_, err := New(nil)
+	require.ErrorIs(t, err, ErrNoAddrs)
+}
+
+func TestPool_RoundRobinSkipsUnhealthy(t *testing.T) {
+	_ = logger.Init()
+
+	p, err := New([]string{"a:1", "b:2", "c:3"})
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"a:1", "b:2", "c:3", "a:1"}, []string{p.Pick(), p.Pick(), p.Pick(), p.Pick()})
+
+	p.Report("b:2", errors.New("connection refused"))
+	require.Equal(t, []string{"c:3", "a:1", "c:3"}, []string{p.Pick(), p.Pick(), p.Pick()})
+
+	p.Report("a:1", errors.New("connection refused"))
+	p.Report("c:3", errors.New("connection refused"))
+	require.Empty(t, p.Healthy())
+	require.NotEmpty(t, p.Pick(), "with every replica down the pool still rotates")
+
+	p.Report("b:2", nil)
+	require.Equal(t, "b:2", p.Pick())
+}
+
+func TestPool_HealthChecks(t *testing.T) {
+	_ = logger.Init()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	deadAddr := closed.Addr().String()
+	closed.Close()
+
+	p, err := New([]string{l.Addr().String(), deadAddr})
+	require.NoError(t, err)
+
+	p.CheckAll()
+	require.Equal(t, []string{l.Addr().String()}, p.Healthy())
+
+	p.check = func(context.Context, string) error { return nil }
+	p.CheckAll()
+	require.Len(t, p.Healthy(), 2)
+}
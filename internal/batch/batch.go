@@ -0,0 +1,152 @@
+package batch
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/models"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
+)
+
+// ModeHeader is the HTTP header (and gRPC metadata key) selecting the batch mode.
+const ModeHeader = "X-Batch-Mode"
+
+// ModePartial applies every valid metric and reports a status for each item.
+// Without it a batch is applied only if all of its metrics are valid.
+const ModePartial = "partial"
+
+// StatusOK and StatusError are the possible values of Result.Status.
+const (
+	StatusOK    = "ok"
+	StatusError = "error"
+)
+
+var (
+	// ErrEmptyID is returned for metrics without a name.
+	ErrEmptyID = errors.New("empty metric id")
+
+	// ErrNoDelta is returned for counter metrics without a delta.
+	ErrNoDelta = errors.New("bad counter metric")
+
+	// ErrNoValue is returned for gauge metrics without a value.
+	ErrNoValue = errors.New("bad gauge metric")
+
+	// ErrUnsupportedType is returned for metrics of unknown type.
+	ErrUnsupportedType = errors.New("unsupported metric type")
+)
+
+// Result describes the outcome for a single metric of a batch.
+type Result struct {
+	// ID is the metric name.
+	ID string `json:"id"`
+
+	// MType is the metric type.
+	MType string `json:"type"`
+
+	// Status is either StatusOK or StatusError.
+	Status string `json:"status"`
+
+	// Error holds the validation error for rejected metrics.
+	Error string `json:"error,omitempty"`
+}
+
+// ItemError reports the first invalid metric of an all-or-nothing batch.
+type ItemError struct {
+	// Index is the position of the metric in the batch.
+	Index int
+
+	// ID is the metric name.
+	ID string
+
+	// Err is the validation error.
+	Err error
+}
+
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("metric #%d (%q): %s", e.Index, e.ID, e.Err)
+}
+
+func (e *ItemError) Unwrap() error {
+	return e.Err
+}
+
+// IsPartial reports whether the given mode value requests partial application.
+func IsPartial(mode string) bool {
+	return strings.EqualFold(strings.TrimSpace(mode), ModePartial)
+}
+
+// Validate checks that a single metric can be applied to storage.
+func Validate(m models.Metrics) error {
+	if m.ID == "" {
+		return ErrEmptyID
+	}
+
+	switch m.MType {
+	case storage.MetricTypeCounter:
+		if _, err := m.GetDelta(); err != nil {
+			return ErrNoDelta
+		}
+	case storage.MetricTypeGauge:
+		if _, err := m.GetValue(); err != nil {
+			return ErrNoValue
+		}
+	default:
+		return ErrUnsupportedType
+	}
+
+	return nil
+}
+
+// Apply validates the whole batch and writes it to storage.
+//
+// In the default mode nothing is written if any metric is invalid and an
+// *ItemError describing the first failure is returned. In partial mode every
+// valid metric is written and the returned results describe each item.
+// The returned names list the metrics that were actually applied. They are
+// written with a single storage update, so readers never see part of a batch.
+func Apply(st storage.Storage, metrics []models.Metrics, partial bool) ([]Result, []string, error) {
+	results := make([]Result, len(metrics))
+	valid := make([]bool, len(metrics))
+
+	var firstErr *ItemError
+	for i, m := range metrics {
+		results[i] = Result{ID: m.ID, MType: m.MType, Status: StatusOK}
+
+		if err := Validate(m); err != nil {
+			results[i].Status = StatusError
+			results[i].Error = err.Error()
+			if firstErr == nil {
+				firstErr = &ItemError{Index: i, ID: m.ID, Err: err}
+			}
+			continue
+		}
+		valid[i] = true
+	}
+
+	if firstErr != nil && !partial {
+		return results, nil, firstErr
+	}
+
+	var (
+		counters []storage.Counter
+		gauges   []storage.Gauge
+	)
+	applied := make([]string, 0, len(metrics))
+	for i, m := range metrics {
+		if !valid[i] {
+			continue
+		}
+
+		switch m.MType {
+		case storage.MetricTypeCounter:
+			counters = append(counters, storage.Counter{Name: m.ID, Type: m.MType, Value: *m.Delta})
+		case storage.MetricTypeGauge:
+			gauges = append(gauges, storage.Gauge{Name: m.ID, Type: m.MType, Value: *m.Value})
+		}
+		applied = append(applied, m.ID)
+	}
+	st.Update(counters, gauges)
+
+	return results, applied, nil
+}
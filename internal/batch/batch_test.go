@@ -0,0 +1,100 @@
+package batch
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/models"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func counter(id string, delta int64) models.Metrics {
+	m := models.Metrics{ID: id, MType: storage.MetricTypeCounter}
+	m.SetDelta(delta)
+	return m
+}
+
+func gauge(id string, value float64) models.Metrics {
+	m := models.Metrics{ID: id, MType: storage.MetricTypeGauge}
+	m.SetValue(value)
+	return m
+}
+
+func TestValidate(t *testing.T) {
+	require.NoError(t, Validate(counter("c", 1)))
+	require.NoError(t, Validate(gauge("g", 1)))
+	require.ErrorIs(t, Validate(gauge("", 1)), ErrEmptyID)
+	require.ErrorIs(t, Validate(models.Metrics{ID: "c", MType: storage.MetricTypeCounter}), ErrNoDelta)
+	require.ErrorIs(t, Validate(models.Metrics{ID: "g", MType: storage.MetricTypeGauge}), ErrNoValue)
+	require.ErrorIs(t, Validate(models.Metrics{ID: "x", MType: "x"}), ErrUnsupportedType)
+}
+
+func TestApply_AllOrNothing(t *testing.T) {
+	st := storage.NewStorage()
+
+	_, applied, err := Apply(st, []models.Metrics{counter("c", 1), gauge("", 1)}, false)
+	require.Error(t, err)
+	require.Empty(t, applied)
+
+	var itemErr *ItemError
+	require.True(t, errors.As(err, &itemErr))
+	require.Equal(t, 1, itemErr.Index)
+
+	_, err = st.GetCounter("c")
+	require.Error(t, err)
+}
+
+func TestApply_Partial(t *testing.T) {
+	st := storage.NewStorage()
+
+	results, applied, err := Apply(st, []models.Metrics{counter("c", 1), gauge("", 1), gauge("g", 2)}, true)
+	require.NoError(t, err)
+	require.Equal(t, []string{"c", "g"}, applied)
+	require.Len(t, results, 3)
+	require.Equal(t, StatusOK, results[0].Status)
+	require.Equal(t, StatusError, results[1].Status)
+	require.Equal(t, ErrEmptyID.Error(), results[1].Error)
+	require.Equal(t, StatusOK, results[2].Status)
+
+	c, err := st.GetCounter("c")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), c.Value)
+}
+
+func TestApply_ReadersNeverSeeHalfBatch(t *testing.T) {
+	st := storage.NewStorage()
+
+	metrics := make([]models.Metrics, 100)
+	for i := range metrics {
+		metrics[i] = counter(fmt.Sprintf("c%d", i), 1)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			if _, _, err := Apply(st, metrics, false); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-done:
+			counters, _ := st.Snapshot()
+			require.Len(t, counters, len(metrics))
+			return
+		default:
+		}
+
+		counters, _ := st.Snapshot()
+		for _, c := range counters {
+			require.Len(t, counters, len(metrics))
+			require.Equal(t, counters[0].Value, c.Value, "batch applied partially")
+		}
+	}
+}
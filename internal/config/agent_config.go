@@ -1,102 +1,344 @@
 package config
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
-	"github.com/caarlos0/env"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/relabel"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/retry"
 )
 
 // AgentConfig stores agent configuration parameters.
+// See load for how the tags map fields to the config file, env and flags.
 type AgentConfig struct {
 	// Addr is the server address in the form host:port.
-	Addr string `env:"ADDRESS" json:"address"`
+	// Several comma separated replicas may be given.
+	Addr string `json:"address" env:"ADDRESS" flag:"a" usage:"server address, comma separated replicas"`
 
-	// PollInterval defines the interval (in seconds) between metric collection.
-	PollInterval int `env:"POLL_INTERVAL" json:"poll_interval"`
+	// PollInterval defines the interval between metric collection.
+	PollInterval time.Duration `json:"poll_interval" env:"POLL_INTERVAL" flag:"p" usage:"poll interval, a duration or seconds"`
 
-	// ReportInterval defines the interval (in seconds) between metric reports.
-	ReportInterval int `env:"REPORT_INTERVAL" json:"report_interval"`
+	// ReportInterval defines the interval between metric reports.
+	ReportInterval time.Duration `json:"report_interval" env:"REPORT_INTERVAL" flag:"r" usage:"report interval, a duration or seconds"`
 
 	// Key is an optional key used for SHA256 request signing.
-	Key string `env:"KEY" json:"-"`
+	Key string `json:"key" env:"KEY" flag:"k" usage:"SHA256 key" secret:"true"`
 
 	// RateLimit defines the maximum number of outgoing requests.
-	RateLimit int `env:"RATE_LIMIT" json:"-"`
+	RateLimit int `json:"rate_limit" env:"RATE_LIMIT" flag:"l" usage:"requests limit"`
 
-	// CryptoKey is the path to private key file
-	CryptoKey string `env:"CRYPTO_KEY" json:"crypto_key"`
+	// CryptoKey is the path to the public key file used to encrypt reports.
+	CryptoKey string `json:"crypto_key" env:"CRYPTO_KEY" flag:"crypto-key" usage:"crypto key filepath"`
 
-	// ConfigPath is the path to json config file
-	ConfigPath string `env:"CONFIG" json:"-"`
+	// ConfigPath is the path to config file
+	ConfigPath string `json:"-" env:"CONFIG" flag:"c,config" usage:"config file path, JSON, YAML or TOML by extension"`
 
-	// GRPCAddr is gRPC server address
-	GRPCAddr string `env:"GRPC_ADDRESS" json:"address_grpc"`
+	// ConfigWatchInterval defines how often the config file is checked for
+	// changes that trigger a reload. Zero disables watching.
+	ConfigWatchInterval time.Duration `json:"config_watch_interval" env:"CONFIG_WATCH_INTERVAL" flag:"config-watch-interval" usage:"interval between config file change checks"`
+
+	// PrintConfig makes the agent print the effective configuration and exit.
+	PrintConfig bool `json:"-" flag:"print-config" usage:"print the effective configuration and exit"`
+
+	// GRPCAddr is gRPC server address. Several comma separated replicas may be given.
+	GRPCAddr string `json:"address_grpc" env:"GRPC_ADDRESS" flag:"g" usage:"gRPC server address, comma separated replicas"`
+
+	// HealthCheckInterval defines how often server replicas are health-checked.
+	HealthCheckInterval time.Duration `json:"health_check_interval" env:"HEALTH_CHECK_INTERVAL" flag:"health-check-interval" usage:"interval between server replica health checks"`
+
+	// RetryMaxAttempts is the total number of attempts to send a report.
+	RetryMaxAttempts int `json:"retry_max_attempts" env:"RETRY_MAX_ATTEMPTS" flag:"retry-attempts" usage:"max attempts to send a report"`
+
+	// RetryInitialBackoff is the delay before the first retry.
+	RetryInitialBackoff time.Duration `json:"retry_initial_backoff" env:"RETRY_INITIAL_BACKOFF" flag:"retry-backoff" usage:"initial delay between retries"`
+
+	// RetryMaxBackoff caps the delay between retries.
+	RetryMaxBackoff time.Duration `json:"retry_max_backoff" env:"RETRY_MAX_BACKOFF" flag:"retry-max-backoff" usage:"max delay between retries"`
+
+	// RetryJitter is the fraction of the retry delay randomized, from 0 to 1.
+	RetryJitter float64 `json:"retry_jitter" env:"RETRY_JITTER" flag:"retry-jitter" usage:"fraction of retry delay randomized"`
+
+	// RetryStatuses lists HTTP status codes that are retried, others fail immediately.
+	RetryStatuses []int `json:"retry_statuses" env:"RETRY_STATUSES" flag:"retry-statuses" usage:"comma separated HTTP statuses that are retried"`
+
+	// SpoolDir is the directory for undelivered reports. Empty disables spooling.
+	SpoolDir string `json:"spool_dir" env:"SPOOL_DIR" flag:"spool-dir" usage:"directory for undelivered reports"`
+
+	// SpoolMaxBytes caps the total size of spooled reports.
+	SpoolMaxBytes int64 `json:"spool_max_bytes" env:"SPOOL_MAX_BYTES" flag:"spool-max-bytes" usage:"max total size of undelivered reports"`
+
+	// SpoolMaxAge defines how long undelivered reports are kept.
+	SpoolMaxAge time.Duration `json:"spool_max_age" env:"SPOOL_MAX_AGE" flag:"spool-max-age" usage:"max age of undelivered reports"`
+
+	// Collectors holds per-collector settings by collector name.
+	Collectors map[string]CollectorConfig `json:"collectors" env:"COLLECTORS" flag:"collectors" usage:"per-collector settings as JSON"`
+
+	// DisabledCollectors lists collectors turned off regardless of Collectors.
+	DisabledCollectors []string `json:"disabled_collectors" env:"DISABLED_COLLECTORS" flag:"disable-collectors" usage:"comma separated collectors to turn off"`
+
+	// AggregateGauges lists regular expressions of gauges whose samples are
+	// aggregated between reports. Empty disables aggregation.
+	AggregateGauges []string `json:"aggregate_gauges" env:"AGGREGATE_GAUGES" flag:"aggregate-gauges" usage:"comma separated patterns of gauges aggregated between reports"`
+
+	// AggregateStats selects the reported statistics: min, max, mean, last and count.
+	AggregateStats []string `json:"aggregate_stats" env:"AGGREGATE_STATS" flag:"aggregate-stats" usage:"comma separated statistics of aggregated gauges"`
+
+	// Destinations lists servers every report is sent to. When set, Addr,
+	// GRPCAddr and the key settings above only serve as defaults for them.
+	Destinations []DestinationConfig `json:"destinations" env:"DESTINATIONS" flag:"destinations" usage:"report destinations as JSON"`
+
+	// Relabel lists the rules applied to every report before it is sent.
+	Relabel []relabel.Rule `json:"relabel" env:"RELABEL" flag:"relabel" usage:"relabel rules as JSON"`
+
+	// PushAddr is the loopback address of the local push endpoint. Empty disables it.
+	PushAddr string `json:"push_address" env:"PUSH_ADDRESS" flag:"push-addr" usage:"loopback address of the local push endpoint"`
+
+	// PushSocket is the Unix socket path of the local push endpoint. Empty disables it.
+	PushSocket string `json:"push_socket" env:"PUSH_SOCKET" flag:"push-socket" usage:"unix socket of the local push endpoint"`
+}
+
+// CollectorConfig stores settings of a single metric collector.
+type CollectorConfig struct {
+	// Enabled turns the collector on or off. When unset, only the collectors
+	// enabled by default run.
+	Enabled *bool `json:"enabled"`
+
+	// PollInterval overrides the agent poll interval for this collector.
+	PollInterval time.Duration `json:"poll_interval"`
+
+	// Options holds collector-specific settings as raw JSON.
+	Options json.RawMessage `json:"options"`
+}
+
+// DestinationConfig stores the settings of one report destination.
+// Unset fields are inherited from the agent configuration.
+type DestinationConfig struct {
+	// Name identifies the destination in logs and names its spool
+	// subdirectory. Names must be unique.
+	Name string `json:"name"`
+
+	// Addr is the HTTP server address. Exactly one of Addr and GRPCAddr must be set.
+	Addr string `json:"address"`
+
+	// GRPCAddr is the gRPC server address.
+	GRPCAddr string `json:"address_grpc"`
+
+	// Key is the SHA256 signing key.
+	Key string `json:"key" secret:"true"`
+
+	// CryptoKey is the path to the public key used to encrypt reports.
+	CryptoKey string `json:"crypto_key"`
+
+	// RetryMaxAttempts is the total number of attempts to send a report.
+	RetryMaxAttempts int `json:"retry_max_attempts"`
+
+	// RetryInitialBackoff is the delay before the first retry.
+	RetryInitialBackoff time.Duration `json:"retry_initial_backoff"`
+
+	// RetryMaxBackoff caps the delay between retries.
+	RetryMaxBackoff time.Duration `json:"retry_max_backoff"`
+
+	// SpoolDir is the directory for reports undelivered to this destination.
+	// Defaults to a subdirectory named after the destination in the agent SpoolDir.
+	SpoolDir string `json:"spool_dir"`
 }
 
 // LoadAgentConfig creates and initializes a AgentConfig instace.
 func LoadAgentConfig() (*AgentConfig, error) {
-	cfg := &AgentConfig{}
+	return loadAgentConfig(os.Args[1:], flag.ExitOnError)
+}
+
+// ReloadAgentConfig reads the agent configuration again from the config file,
+// environment and command line. Unlike LoadAgentConfig it never exits the
+// process, so a running agent can keep its current configuration when the
+// new one is broken.
+func ReloadAgentConfig() (*AgentConfig, error) {
+	return reloadAgentConfig(os.Args[1:])
+}
 
-	cfg.ConfigPath = os.Getenv("CONFIG")
-	if p, ok := findConfigPath(os.Args[1:]); ok {
-		cfg.ConfigPath = p
+func reloadAgentConfig(args []string) (*AgentConfig, error) {
+	return loadAgentConfig(args, flag.ContinueOnError)
+}
+
+func loadAgentConfig(args []string, handling flag.ErrorHandling) (*AgentConfig, error) {
+	cfg := defaultAgentConfig()
+	if err := load(cfg, args, handling); err != nil {
+		return nil, err
 	}
 
-	if cfg.ConfigPath != "" {
-		if err := applyAgentJSON(cfg, cfg.ConfigPath); err != nil {
-			return nil, fmt.Errorf("cannot apply json config: %w", err)
-		}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
 	}
 
-	flag.StringVar(&cfg.Addr, `a`, cfg.Addr, `server address`)
-	flag.IntVar(&cfg.PollInterval, `p`, cfg.PollInterval, `poll interval`)
-	flag.IntVar(&cfg.ReportInterval, `r`, cfg.ReportInterval, `poll interval`)
-	flag.StringVar(&cfg.Key, `k`, cfg.Key, `SHA256 key`)
-	flag.IntVar(&cfg.RateLimit, `l`, cfg.RateLimit, `requests limit`)
-	flag.StringVar(&cfg.CryptoKey, "crypto-key", cfg.CryptoKey, "crypto key filepath")
-	flag.StringVar(&cfg.GRPCAddr, "g", cfg.GRPCAddr, "gRPC server address")
-	flag.Parse()
+	return cfg, nil
+}
 
-	if err := env.Parse(cfg); err != nil {
-		return nil, fmt.Errorf("cannot read env config: %w", err)
-	}
+// defaultAgentConfig returns the configuration used when nothing is set.
+func defaultAgentConfig() *AgentConfig {
+	p := retry.DefaultPolicy()
 
-	if cfg.Addr == "" {
-		cfg.Addr = "localhost:8080"
+	return &AgentConfig{
+		Addr:                "localhost:8080",
+		PollInterval:        2 * time.Second,
+		ReportInterval:      10 * time.Second,
+		RateLimit:           1,
+		GRPCAddr:            "localhost:3200",
+		HealthCheckInterval: 10 * time.Second,
+		RetryMaxAttempts:    p.MaxAttempts,
+		RetryInitialBackoff: p.InitialBackoff,
+		RetryMaxBackoff:     p.MaxBackoff,
+		RetryJitter:         p.Jitter,
+		RetryStatuses:       p.RetryableStatuses,
+		SpoolMaxBytes:       16 << 20,
+		SpoolMaxAge:         24 * time.Hour,
 	}
+}
 
-	if cfg.PollInterval == 0 {
-		cfg.PollInterval = 2
-	}
+// Validate reports every setting the agent cannot run with as a *ValidationError.
+func (cfg *AgentConfig) Validate() error {
+	var v validator
 
-	if cfg.ReportInterval == 0 {
-		cfg.ReportInterval = 10
+	v.addresses("address", cfg.Addr)
+	v.addresses("address_grpc", cfg.GRPCAddr)
+	if len(cfg.Destinations) == 0 {
+		v.check(cfg.Addr != "" || cfg.GRPCAddr != "", "address", "either address or address_grpc must be set")
 	}
+	v.positive("poll_interval", cfg.PollInterval)
+	v.positive("report_interval", cfg.ReportInterval)
+	v.check(cfg.RateLimit > 0, "rate_limit", "must be positive, got %d", cfg.RateLimit)
+	v.file("crypto_key", cfg.CryptoKey)
+	v.nonNegative("config_watch_interval", cfg.ConfigWatchInterval)
+	v.positive("health_check_interval", cfg.HealthCheckInterval)
 
-	if cfg.RateLimit == 0 {
-		cfg.RateLimit = 1
+	v.check(cfg.RetryMaxAttempts > 0, "retry_max_attempts", "must be positive, got %d", cfg.RetryMaxAttempts)
+	v.nonNegative("retry_initial_backoff", cfg.RetryInitialBackoff)
+	v.nonNegative("retry_max_backoff", cfg.RetryMaxBackoff)
+	v.check(cfg.RetryJitter >= 0 && cfg.RetryJitter <= 1, "retry_jitter", "must be between 0 and 1, got %g", cfg.RetryJitter)
+	for _, code := range cfg.RetryStatuses {
+		v.check(code >= 100 && code <= 599, "retry_statuses", "invalid HTTP status %d", code)
 	}
 
-	if envCryptoKey, ok := os.LookupEnv("CRYPTO_KEY"); ok {
-		cfg.CryptoKey = envCryptoKey
+	v.check(cfg.SpoolMaxBytes > 0, "spool_max_bytes", "must be positive, got %d", cfg.SpoolMaxBytes)
+	v.positive("spool_max_age", cfg.SpoolMaxAge)
+
+	for name, c := range cfg.Collectors {
+		v.nonNegative("collectors."+name+".poll_interval", c.PollInterval)
 	}
 
-	if cfg.GRPCAddr == "" {
-		cfg.GRPCAddr = "localhost:3200"
+	names := make(map[string]bool, len(cfg.Destinations))
+	spoolDirs := make(map[string]string, len(cfg.Destinations))
+	for i, d := range cfg.Destinations {
+		if !validDestinationName(d.Name) {
+			v.fail(fmt.Sprintf("destinations[%d].name", i), "must be a non-empty name without path separators, got %q", d.Name)
+		} else if names[d.Name] {
+			v.fail(fmt.Sprintf("destinations[%d].name", i), "duplicate destination name %q", d.Name)
+		}
+		names[d.Name] = true
+
+		if dir := cfg.ForDestination(d).SpoolDir; dir != "" {
+			dir = filepath.Clean(dir)
+			if other, ok := spoolDirs[dir]; ok {
+				v.fail("destinations."+d.Name+".spool_dir", "spool dir %s is already used by destination %s", dir, other)
+			}
+			spoolDirs[dir] = d.Name
+		}
+
+		prefix := "destinations." + d.Name + "."
+		v.check((d.Addr == "") != (d.GRPCAddr == ""), prefix+"address", "exactly one of address and address_grpc must be set")
+		v.addresses(prefix+"address", d.Addr)
+		v.addresses(prefix+"address_grpc", d.GRPCAddr)
+		v.file(prefix+"crypto_key", d.CryptoKey)
+		v.check(d.RetryMaxAttempts >= 0, prefix+"retry_max_attempts", "must not be negative, got %d", d.RetryMaxAttempts)
 	}
 
-	return cfg, nil
+	v.address("push_address", cfg.PushAddr)
+
+	return v.err()
 }
 
 // PollTicker returns a ticker that triggers metric collection.
 func (cfg *AgentConfig) PollTicker() *time.Ticker {
-	return time.NewTicker(time.Duration(cfg.PollInterval) * time.Second)
+	return time.NewTicker(cfg.PollInterval)
 }
 
 // ReportTicker return a ticker that triggers metric reporting.
 func (cfg *AgentConfig) ReportTicker() *time.Ticker {
-	return time.NewTicker(time.Duration(cfg.ReportInterval) * time.Second)
+	return time.NewTicker(cfg.ReportInterval)
+}
+
+// CollectorEnabled reports whether the named collector should run.
+// byDefault is used when the collector is neither disabled nor switched explicitly.
+func (cfg *AgentConfig) CollectorEnabled(name string, byDefault bool) bool {
+	if slices.Contains(cfg.DisabledCollectors, name) {
+		return false
+	}
+	c, ok := cfg.Collectors[name]
+	if !ok || c.Enabled == nil {
+		return byDefault
+	}
+	return *c.Enabled
+}
+
+// CollectorInterval returns the poll interval of the named collector.
+func (cfg *AgentConfig) CollectorInterval(name string) time.Duration {
+	if c, ok := cfg.Collectors[name]; ok && c.PollInterval > 0 {
+		return c.PollInterval
+	}
+	return cfg.PollInterval
+}
+
+// ForDestination returns a copy of cfg with the settings of d applied,
+// describing a single HTTP or gRPC destination.
+func (cfg *AgentConfig) ForDestination(d DestinationConfig) AgentConfig {
+	c := *cfg
+	c.Addr, c.GRPCAddr = d.Addr, d.GRPCAddr
+	c.Destinations = nil
+
+	if d.Key != "" {
+		c.Key = d.Key
+	}
+	if d.CryptoKey != "" {
+		c.CryptoKey = d.CryptoKey
+	}
+	if d.RetryMaxAttempts > 0 {
+		c.RetryMaxAttempts = d.RetryMaxAttempts
+	}
+	if d.RetryInitialBackoff > 0 {
+		c.RetryInitialBackoff = d.RetryInitialBackoff
+	}
+	if d.RetryMaxBackoff > 0 {
+		c.RetryMaxBackoff = d.RetryMaxBackoff
+	}
+	switch {
+	case d.SpoolDir != "":
+		c.SpoolDir = d.SpoolDir
+	case c.SpoolDir != "":
+		c.SpoolDir = filepath.Join(c.SpoolDir, d.Name)
+	}
+
+	return c
+}
+
+func validDestinationName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+// RetryPolicy returns the retry policy used by senders. All attempts to
+// send a report must fit in the report interval, so reports never queue up
+// behind retries of the previous one.
+func (cfg *AgentConfig) RetryPolicy() retry.Policy {
+	p := retry.DefaultPolicy()
+	p.MaxAttempts = cfg.RetryMaxAttempts
+	p.InitialBackoff = cfg.RetryInitialBackoff
+	p.MaxBackoff = cfg.RetryMaxBackoff
+	p.Jitter = cfg.RetryJitter
+	p.RetryableStatuses = cfg.RetryStatuses
+	p.Budget = cfg.ReportInterval
+	return p
 }
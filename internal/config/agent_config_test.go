@@ -0,0 +1,159 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloadAgentConfig_RejectsInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.json")
+	t.Setenv("CONFIG", path)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"report_interval":"5s"}`), 0o600))
+	cfg, err := reloadAgentConfig(nil)
+	require.NoError(t, err)
+	require.Equal(t, 5*time.Second, cfg.ReportInterval)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"report_interval":"soon"}`), 0o600))
+	_, err = reloadAgentConfig(nil)
+	require.Error(t, err)
+
+	_, err = reloadAgentConfig([]string{"-r", "0"})
+	require.Error(t, err)
+}
+
+func TestLoadAgentConfig_Intervals(t *testing.T) {
+	t.Setenv("CONFIG", "")
+
+	tests := []struct {
+		name   string
+		json   string
+		args   []string
+		env    map[string]string
+		poll   time.Duration
+		report time.Duration
+	}{
+		{
+			name:   "defaults",
+			poll:   2 * time.Second,
+			report: 10 * time.Second,
+		},
+		{
+			name:   "sub-second json",
+			json:   `{"poll_interval":"500ms","report_interval":"1.5s"}`,
+			poll:   500 * time.Millisecond,
+			report: 1500 * time.Millisecond,
+		},
+		{
+			name:   "json seconds",
+			json:   `{"poll_interval":3,"report_interval":"7"}`,
+			poll:   3 * time.Second,
+			report: 7 * time.Second,
+		},
+		{
+			name:   "flags",
+			args:   []string{"-p", "250ms", "-r", "4"},
+			poll:   250 * time.Millisecond,
+			report: 4 * time.Second,
+		},
+		{
+			name:   "flags override env",
+			args:   []string{"-p", "250ms"},
+			env:    map[string]string{"POLL_INTERVAL": "1", "REPORT_INTERVAL": "100ms"},
+			poll:   250 * time.Millisecond,
+			report: 100 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.json != "" {
+				path := filepath.Join(t.TempDir(), "agent.json")
+				require.NoError(t, os.WriteFile(path, []byte(tt.json), 0o600))
+				t.Setenv("CONFIG", path)
+			}
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			cfg, err := reloadAgentConfig(tt.args)
+			require.NoError(t, err)
+			require.Equal(t, tt.poll, cfg.PollInterval)
+			require.Equal(t, tt.report, cfg.ReportInterval)
+		})
+	}
+}
+
+func TestLoadAgentConfig_InvalidIntervals(t *testing.T) {
+	t.Setenv("CONFIG", "")
+
+	tests := []struct {
+		name string
+		json string
+		args []string
+		env  map[string]string
+	}{
+		{name: "zero json", json: `{"poll_interval":"0s"}`},
+		{name: "negative json", json: `{"report_interval":"-1s"}`},
+		{name: "malformed json", json: `{"report_interval":"fast"}`},
+		{name: "fractional seconds json", json: `{"report_interval":0.5}`},
+		{name: "negative collector interval", json: `{"collectors":{"runtime":{"poll_interval":"-1s"}}}`},
+		{name: "zero flag", args: []string{"-r", "0"}},
+		{name: "malformed flag", args: []string{"-p", "2x"}},
+		{name: "malformed env", env: map[string]string{"REPORT_INTERVAL": "ten"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.json != "" {
+				path := filepath.Join(t.TempDir(), "agent.json")
+				require.NoError(t, os.WriteFile(path, []byte(tt.json), 0o600))
+				t.Setenv("CONFIG", path)
+			}
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			_, err := reloadAgentConfig(tt.args)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestAgentConfig_RetryPolicy(t *testing.T) {
+	t.Setenv("CONFIG", "")
+
+	cfg, err := reloadAgentConfig([]string{"-retry-jitter", "0", "-retry-statuses", "", "-r", "3s"})
+	require.NoError(t, err)
+
+	p := cfg.RetryPolicy()
+	require.Zero(t, p.Jitter, "jitter can be turned off")
+	require.Empty(t, p.RetryableStatuses)
+	require.Equal(t, 3*time.Second, p.Budget, "retries must fit in the report interval")
+}
+
+func TestAgentConfig_DestinationSpoolDirs(t *testing.T) {
+	t.Setenv("CONFIG", "")
+
+	cfg, err := reloadAgentConfig([]string{
+		"-spool-dir", "/var/spool/agent",
+		"-destinations", `[{"name":"main","address":"localhost:8080"},{"name":"backup","address_grpc":"localhost:3200"}]`,
+	})
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join("/var/spool/agent", "main"), cfg.ForDestination(cfg.Destinations[0]).SpoolDir)
+	require.Equal(t, filepath.Join("/var/spool/agent", "backup"), cfg.ForDestination(cfg.Destinations[1]).SpoolDir)
+
+	cfg.Destinations[1].SpoolDir = "/var/spool/agent/main/"
+	require.ErrorContains(t, cfg.Validate(), "already used by destination main")
+
+	cfg.Destinations[1].SpoolDir = ""
+	cfg.Destinations[1].Name = "main"
+	require.ErrorContains(t, cfg.Validate(), "duplicate destination name")
+
+	cfg.Destinations[1].Name = "../main"
+	require.ErrorContains(t, cfg.Validate(), "destinations[1].name")
+}
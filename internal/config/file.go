@@ -0,0 +1,145 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// warnf reports problems that do not prevent loading the configuration.
+// The logger is not initialized yet while the configuration is loaded.
+var warnf = log.Printf
+
+var rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+
+// readConfigFile reads the config file at path. Its format is chosen by the
+// extension: .yaml or .yml for YAML, .toml for TOML and JSON otherwise.
+// Values are returned as encoding/json decodes them.
+func readConfigFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read config file: %w", err)
+	}
+
+	var values map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &values); err != nil {
+			return nil, fmt.Errorf("cannot parse config yaml: %w", err)
+		}
+	case ".toml":
+		if values, err = decodeTOML(data); err != nil {
+			return nil, fmt.Errorf("cannot parse config toml: %w", err)
+		}
+	default:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&values); err != nil {
+			return nil, fmt.Errorf("cannot parse config json: %w", err)
+		}
+	}
+	return values, nil
+}
+
+// decodeTOML decodes a TOML document into maps, slices and scalar values as
+// encoding/json would decode the equivalent JSON. Integers are int64 and
+// floats float64.
+func decodeTOML(data []byte) (map[string]any, error) {
+	var values map[string]any
+	if _, err := toml.Decode(string(data), &values); err != nil {
+		return nil, err
+	}
+	return normalizeTOML(values).(map[string]any), nil
+}
+
+// normalizeTOML turns arrays of tables, decoded as []map[string]any, into
+// []any like every other array.
+func normalizeTOML(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			v[k] = normalizeTOML(e)
+		}
+		return v
+	case []map[string]any:
+		list := make([]any, len(v))
+		for i, e := range v {
+			list[i] = normalizeTOML(e)
+		}
+		return list
+	case []any:
+		for i, e := range v {
+			v[i] = normalizeTOML(e)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// unknownKeys returns the keys of value, decoded from a config file, that
+// match no json key of t. Nested keys are prefixed with the path to them.
+func unknownKeys(t reflect.Type, value any, prefix string) []string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == durationType || t == rawMessageType {
+		return nil
+	}
+
+	var unknown []string
+	switch t.Kind() {
+	case reflect.Struct:
+		m, _ := value.(map[string]any)
+		known := make(map[string]reflect.Type)
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			key, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+			if key == "-" || !sf.IsExported() {
+				continue
+			}
+			if key == "" {
+				key = sf.Name
+			}
+			known[key] = sf.Type
+		}
+		for _, k := range sortedKeys(m) {
+			ft, ok := known[k]
+			if !ok {
+				unknown = append(unknown, prefix+k)
+				continue
+			}
+			unknown = append(unknown, unknownKeys(ft, m[k], prefix+k+".")...)
+		}
+	case reflect.Map:
+		m, _ := value.(map[string]any)
+		for _, k := range sortedKeys(m) {
+			unknown = append(unknown, unknownKeys(t.Elem(), m[k], prefix+k+".")...)
+		}
+	case reflect.Slice:
+		list, _ := value.([]any)
+		for i, v := range list {
+			p := fmt.Sprintf("%s[%d].", strings.TrimSuffix(prefix, "."), i)
+			unknown = append(unknown, unknownKeys(t.Elem(), v, p)...)
+		}
+	}
+	return unknown
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,241 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func captureWarnings(t *testing.T) *[]string {
+	t.Helper()
+	var warnings []string
+	prev := warnf
+	warnf = func(format string, args ...any) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+	t.Cleanup(func() { warnf = prev })
+	return &warnings
+}
+
+func TestLoadAgentConfig_FileFormats(t *testing.T) {
+	files := map[string]string{
+		"agent.json": `{
+			"address": "localhost:9090",
+			"poll_interval": "500ms",
+			"report_interval": 4,
+			"rate_limit": 3,
+			"retry_statuses": [502, 503],
+			"collectors": {"runtime": {"enabled": false}},
+			"destinations": [{"name": "backup", "address_grpc": "localhost:3201", "retry_initial_backoff": 1, "retry_max_backoff": "2s"}]
+		}`,
+		"agent.yaml": `
+address: localhost:9090
+poll_interval: 500ms
+report_interval: 4
+rate_limit: 3
+retry_statuses: [502, 503]
+collectors:
+  runtime:
+    enabled: false
+destinations:
+  - name: backup
+    address_grpc: localhost:3201
+    retry_initial_backoff: 1
+    retry_max_backoff: 2s
+`,
+		"agent.TOML": `
+address = "localhost:9090"
+poll_interval = "500ms"
+report_interval = 4
+rate_limit = 3
+retry_statuses = [502, 503]
+
+[collectors.runtime]
+enabled = false
+
+[[destinations]]
+name = "backup"
+address_grpc = "localhost:3201"
+retry_initial_backoff = 1
+retry_max_backoff = "2s"
+`,
+	}
+
+	for name, data := range files {
+		t.Run(name, func(t *testing.T) {
+			warnings := captureWarnings(t)
+			path := filepath.Join(t.TempDir(), name)
+			require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+			t.Setenv("CONFIG", path)
+
+			cfg, err := reloadAgentConfig(nil)
+			require.NoError(t, err)
+			require.Empty(t, *warnings)
+
+			require.Equal(t, "localhost:9090", cfg.Addr)
+			require.Equal(t, 500*time.Millisecond, cfg.PollInterval)
+			require.Equal(t, 4*time.Second, cfg.ReportInterval)
+			require.Equal(t, 3, cfg.RateLimit)
+			require.Equal(t, []int{502, 503}, cfg.RetryStatuses)
+			require.NotNil(t, cfg.Collectors["runtime"].Enabled)
+			require.False(t, *cfg.Collectors["runtime"].Enabled)
+			require.Len(t, cfg.Destinations, 1)
+			require.Equal(t, "localhost:3201", cfg.Destinations[0].GRPCAddr)
+			require.Equal(t, time.Second, cfg.Destinations[0].RetryInitialBackoff)
+			require.Equal(t, 2*time.Second, cfg.Destinations[0].RetryMaxBackoff)
+		})
+	}
+}
+
+func TestLoadServerConfig_UnknownKeys(t *testing.T) {
+	warnings := captureWarnings(t)
+	path := filepath.Join(t.TempDir(), "server.yml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+address: localhost:8081
+stor_interval: 10s
+ConfigPath: other.yml
+`), 0o600))
+	t.Setenv("CONFIG", path)
+
+	cfg, err := reloadServerConfig(nil)
+	require.NoError(t, err)
+	require.Equal(t, "localhost:8081", cfg.Addr)
+	require.Len(t, *warnings, 2)
+	require.Contains(t, (*warnings)[0], `"ConfigPath"`)
+	require.Contains(t, (*warnings)[1], `"stor_interval"`)
+}
+
+func TestUnknownKeys_Nested(t *testing.T) {
+	values, err := decodeTOML([]byte(`
+poll_interval = "1s"
+colour = "blue"
+
+[collectors.runtime]
+enabled = true
+interval = "1s"
+options = { anything = 1 }
+
+[[destinations]]
+address = "localhost:8080"
+retries = 3
+
+[[relabel]]
+action = "drop"
+matches = "go_.*"
+`))
+	require.NoError(t, err)
+
+	require.Equal(t, []string{
+		"collectors.runtime.interval",
+		"colour",
+		"destinations[0].retries",
+		"relabel[0].matches",
+	}, unknownKeys(reflect.TypeOf(AgentConfig{}), values, ""))
+}
+
+func TestLoadServerConfig_InvalidFile(t *testing.T) {
+	for name, data := range map[string]string{
+		"server.json": `{"address": `,
+		"server.yaml": "address: [",
+		"server.toml": `address = `,
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+			t.Setenv("CONFIG", path)
+
+			_, err := reloadServerConfig(nil)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestDecodeTOML(t *testing.T) {
+	doc := `
+# agent settings
+address = "localhost:8080"   # comment after value
+poll_interval = '500ms'
+rate_limit = 1_000
+retry_jitter = 0.25
+mask = 0x1F
+restore = true
+retry_statuses = [
+  502,
+  503, # trailing comma is allowed
+]
+"quoted key" = "tab\tand \u00e9"
+multi = """
+first \
+  second"""
+literal = '''C:\path'''
+nested.dotted = "x"
+
+[collectors.runtime]
+enabled = false
+options = { interval = "1s", tags = ["a", "b"] }
+
+[[destinations]]
+name = "primary"
+address = "localhost:8080"
+
+[[destinations]]
+name = "backup"
+address_grpc = "localhost:3200"
+`
+
+	got, err := decodeTOML([]byte(doc))
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{
+		"address":        "localhost:8080",
+		"poll_interval":  "500ms",
+		"rate_limit":     int64(1000),
+		"retry_jitter":   0.25,
+		"mask":           int64(31),
+		"restore":        true,
+		"retry_statuses": []any{int64(502), int64(503)},
+		"quoted key":     "tab\tand é",
+		"multi":          "first second",
+		"literal":        `C:\path`,
+		"nested":         map[string]any{"dotted": "x"},
+		"collectors": map[string]any{
+			"runtime": map[string]any{
+				"enabled": false,
+				"options": map[string]any{"interval": "1s", "tags": []any{"a", "b"}},
+			},
+		},
+		"destinations": []any{
+			map[string]any{"name": "primary", "address": "localhost:8080"},
+			map[string]any{"name": "backup", "address_grpc": "localhost:3200"},
+		},
+	}, got)
+}
+
+func TestDecodeTOML_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+	}{
+		{name: "missing value", doc: "a ="},
+		{name: "missing equals", doc: "a 1"},
+		{name: "unterminated string", doc: `a = "abc`},
+		{name: "duplicate key", doc: "a = 1\na = 2"},
+		{name: "duplicate table", doc: "[a]\n[a]"},
+		{name: "key is not a table", doc: "a = 1\n[a.b]"},
+		{name: "two values on a line", doc: "a = 1 b = 2"},
+		{name: "unclosed array", doc: "a = [1, 2"},
+		{name: "invalid number", doc: "a = 12ab"},
+		{name: "invalid escape", doc: `a = "\q"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeTOML([]byte(tt.doc))
+			require.Error(t, err)
+		})
+	}
+}
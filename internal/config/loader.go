@@ -0,0 +1,295 @@
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The loader fills a configuration struct from four layers, each overriding
+// the previous one: defaults set by the caller, the config file, environment
+// variables and command line flags. Fields take part according to their tags:
+//
+//	json  - key in the config file
+//	env   - environment variable
+//	flag  - comma separated flag names, the first one shown in usage
+//	usage - flag description
+//
+// The config file may be JSON, YAML or TOML, see readConfigFile.
+// Every source accepts the same value syntax. Durations may be given as
+// plain integers meaning seconds, lists as comma separated values, and
+// structured fields such as collectors as JSON in env and flags.
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// field describes one tagged configuration field.
+type field struct {
+	index   int
+	env     string
+	flags   []string
+	usage   string
+	jsonKey string
+}
+
+func fieldsOf(t reflect.Type) []field {
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		f := field{index: i, env: sf.Tag.Get("env"), usage: sf.Tag.Get("usage")}
+		if key, _, _ := strings.Cut(sf.Tag.Get("json"), ","); key != "-" {
+			f.jsonKey = key
+		}
+		if fl := sf.Tag.Get("flag"); fl != "" {
+			f.flags = strings.Split(fl, ",")
+		}
+
+		if f.jsonKey != "" || f.env != "" || len(f.flags) > 0 {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+type flagValue struct {
+	field field
+	name  string
+	value string
+}
+
+// load applies the config file, environment and args on top of the
+// defaults already in cfg, which must be a pointer to a struct with a
+// ConfigPath string field.
+func load(cfg any, args []string, handling flag.ErrorHandling) error {
+	v := reflect.ValueOf(cfg).Elem()
+	fields := fieldsOf(v.Type())
+
+	fs := flag.NewFlagSet(os.Args[0], handling)
+	var set []flagValue
+	for _, f := range fields {
+		for i, name := range f.flags {
+			usage := f.usage
+			if i > 0 {
+				usage = "alias of -" + f.flags[0]
+			} else if def := formatValue(v.Field(f.index)); def != "" && def != "false" {
+				usage = fmt.Sprintf("%s (default %s)", usage, def)
+			}
+
+			record := func(s string) error {
+				set = append(set, flagValue{field: f, name: name, value: s})
+				return nil
+			}
+			if v.Field(f.index).Kind() == reflect.Bool {
+				fs.BoolFunc(name, usage, record)
+			} else {
+				fs.Func(name, usage, record)
+			}
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("cannot parse flags: %w", err)
+	}
+
+	pathField, _ := v.Type().FieldByName("ConfigPath")
+	path := v.FieldByIndex(pathField.Index)
+	if p, ok := os.LookupEnv("CONFIG"); ok {
+		path.SetString(p)
+	}
+	for _, s := range set {
+		if s.field.index == pathField.Index[0] {
+			path.SetString(s.value)
+		}
+	}
+
+	if p := path.String(); p != "" {
+		if err := applyFile(v, fields, p); err != nil {
+			return fmt.Errorf("cannot apply config file: %w", err)
+		}
+	}
+
+	for _, f := range fields {
+		if f.env == "" {
+			continue
+		}
+		s, ok := os.LookupEnv(f.env)
+		if !ok {
+			continue
+		}
+		if err := setString(v.Field(f.index), s); err != nil {
+			return fmt.Errorf("invalid env %s: %w", f.env, err)
+		}
+	}
+
+	for _, s := range set {
+		if err := setString(v.Field(s.field.index), s.value); err != nil {
+			return fmt.Errorf("invalid flag -%s: %w", s.name, err)
+		}
+	}
+
+	return nil
+}
+
+// applyFile sets every field whose json key is present in the config file at
+// path and warns about keys matching no field.
+func applyFile(v reflect.Value, fields []field, path string) error {
+	values, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range unknownKeys(v.Type(), values, "") {
+		warnf("config file %s: unknown key %q", path, key)
+	}
+
+	for _, f := range fields {
+		if f.jsonKey == "" {
+			continue
+		}
+		value, ok := values[f.jsonKey]
+		if !ok {
+			continue
+		}
+		r, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", f.jsonKey, err)
+		}
+		if err := setJSON(v.Field(f.index), r); err != nil {
+			return fmt.Errorf("invalid %s: %w", f.jsonKey, err)
+		}
+	}
+	return nil
+}
+
+// setString sets fv from its text form used in env and flags.
+func setString(fv reflect.Value, s string) error {
+	switch fv.Interface().(type) {
+	case time.Duration:
+		d, err := parseInterval(s)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	case map[string]CollectorConfig, []DestinationConfig:
+		return setJSON(fv, json.RawMessage(s))
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() == reflect.Struct {
+			return setJSON(fv, json.RawMessage(s))
+		}
+		var parts []string
+		if s != "" {
+			parts = strings.Split(s, ",")
+		}
+		list := reflect.MakeSlice(fv.Type(), len(parts), len(parts))
+		for i, p := range parts {
+			if err := setString(list.Index(i), strings.TrimSpace(p)); err != nil {
+				return err
+			}
+		}
+		fv.Set(list)
+	default:
+		return setJSON(fv, json.RawMessage(s))
+	}
+	return nil
+}
+
+// setJSON sets fv from its config file form.
+func setJSON(fv reflect.Value, r json.RawMessage) error {
+	switch fv.Interface().(type) {
+	case time.Duration:
+		d, ok, err := parseJSONInterval(r)
+		if err != nil {
+			return err
+		}
+		if ok {
+			fv.SetInt(int64(d))
+		}
+		return nil
+	case map[string]CollectorConfig:
+		collectors, err := parseCollectors(r)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(collectors))
+		return nil
+	case []DestinationConfig:
+		destinations, err := parseDestinations(r)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(destinations))
+		return nil
+	}
+
+	p := reflect.New(fv.Type())
+	if err := json.Unmarshal(r, p.Interface()); err != nil {
+		return err
+	}
+	fv.Set(p.Elem())
+	return nil
+}
+
+// formatValue returns the text form of a scalar or list value, as accepted by setString.
+func formatValue(fv reflect.Value) string {
+	if fv.Type() == durationType {
+		if fv.Int() == 0 {
+			return ""
+		}
+		return time.Duration(fv.Int()).String()
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		return fv.String()
+	case reflect.Bool:
+		return strconv.FormatBool(fv.Bool())
+	case reflect.Int, reflect.Int64:
+		if fv.Int() == 0 {
+			return ""
+		}
+		return strconv.FormatInt(fv.Int(), 10)
+	case reflect.Float64:
+		if fv.Float() == 0 {
+			return ""
+		}
+		return strconv.FormatFloat(fv.Float(), 'g', -1, 64)
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() == reflect.Struct {
+			return ""
+		}
+		parts := make([]string, fv.Len())
+		for i := range parts {
+			parts[i] = formatValue(fv.Index(i))
+		}
+		return strings.Join(parts, ",")
+	}
+	return ""
+}
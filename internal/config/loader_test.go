@@ -0,0 +1,132 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+	return path
+}
+
+func TestLoadServerConfig_Precedence(t *testing.T) {
+	t.Setenv("CONFIG", writeConfig(t, `{
+		"address": "file:1",
+		"address_grpc": "file:2",
+		"store_interval": "30s",
+		"key": "file-key",
+		"audit_file": "audit.log"
+	}`))
+	t.Setenv("ADDRESS", "env:1")
+	t.Setenv("STORE_INTERVAL", "20s")
+
+	cfg, err := reloadServerConfig([]string{"-i", "10s"})
+	require.NoError(t, err)
+
+	require.Equal(t, "env:1", cfg.Addr)
+	require.Equal(t, "file:2", cfg.GRPCAddr)
+	require.Equal(t, 10*time.Second, cfg.StoreInterval)
+	require.Equal(t, "file-key", cfg.Key)
+	require.Equal(t, "audit.log", cfg.AuditFile)
+	require.Equal(t, defaultServerConfig().FileStoragePath, cfg.FileStoragePath)
+}
+
+func TestLoadAgentConfig_AllSources(t *testing.T) {
+	t.Setenv("CONFIG", "")
+
+	t.Run("file", func(t *testing.T) {
+		t.Setenv("CONFIG", writeConfig(t, `{"key":"secret","rate_limit":4,"disabled_collectors":["gopsutil"]}`))
+
+		cfg, err := reloadAgentConfig(nil)
+		require.NoError(t, err)
+		require.Equal(t, "secret", cfg.Key)
+		require.Equal(t, 4, cfg.RateLimit)
+		require.Equal(t, []string{"gopsutil"}, cfg.DisabledCollectors)
+	})
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("RATE_LIMIT", "3")
+		t.Setenv("DISABLED_COLLECTORS", "gopsutil, runtime")
+
+		cfg, err := reloadAgentConfig(nil)
+		require.NoError(t, err)
+		require.Equal(t, 3, cfg.RateLimit)
+		require.Equal(t, []string{"gopsutil", "runtime"}, cfg.DisabledCollectors)
+	})
+
+	t.Run("flags", func(t *testing.T) {
+		cfg, err := reloadAgentConfig([]string{"-config-watch-interval", "5s", "-destinations", `[{"address":"localhost:9090"}]`})
+		require.NoError(t, err)
+		require.Equal(t, 5*time.Second, cfg.ConfigWatchInterval)
+		require.Len(t, cfg.Destinations, 1)
+		require.Equal(t, "localhost:9090", cfg.Destinations[0].Addr)
+	})
+}
+
+func TestLoadServerConfig_ValidationError(t *testing.T) {
+	t.Setenv("CONFIG", "")
+
+	_, err := reloadServerConfig([]string{
+		"-a", "localhost",
+		"-t", "10.0.0.0",
+		"-crypto-key", filepath.Join(t.TempDir(), "missing.pem"),
+		"-idempotency-window", "-1s",
+	})
+
+	var verr *ValidationError
+	require.True(t, errors.As(err, &verr), "got %v", err)
+
+	var fields []string
+	for _, f := range verr.Fields {
+		fields = append(fields, f.Field)
+	}
+	require.ElementsMatch(t, []string{"address", "trusted_subnet", "crypto_key", "idempotency_window"}, fields)
+}
+
+func TestPrint_RedactsSecrets(t *testing.T) {
+	t.Setenv("CONFIG", "")
+
+	cfg, err := reloadAgentConfig([]string{
+		"-k", "hmac-secret",
+		"-p", "500ms",
+		"-destinations", `[{"address":"localhost:9090","key":"dest-secret"}]`,
+	})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, Print(&buf, cfg))
+	require.NotContains(t, buf.String(), "hmac-secret")
+	require.NotContains(t, buf.String(), "dest-secret")
+
+	var printed map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &printed))
+	require.Equal(t, redacted, printed["key"])
+	require.Equal(t, "500ms", printed["poll_interval"])
+	require.NotContains(t, printed, "ConfigPath")
+
+	// The printed configuration loads back to the same settings.
+	t.Setenv("CONFIG", writeConfig(t, buf.String()))
+	again, err := reloadAgentConfig(nil)
+	require.NoError(t, err)
+	require.Equal(t, cfg.PollInterval, again.PollInterval)
+	require.Equal(t, cfg.Addr, again.Addr)
+}
+
+func TestLoadAgentConfig_NullIntervalIsUnset(t *testing.T) {
+	t.Setenv("CONFIG", writeConfig(t, `{"poll_interval": null, "report_interval": 5}`))
+
+	cfg, err := reloadAgentConfig(nil)
+	require.NoError(t, err)
+	require.Equal(t, defaultAgentConfig().PollInterval, cfg.PollInterval)
+	require.Equal(t, 5*time.Second, cfg.ReportInterval)
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// redacted replaces the value of secret settings in printed configurations.
+const redacted = "[REDACTED]"
+
+// Print writes cfg as indented JSON in the config file format, so the output
+// can be used as a config file. Durations are written as strings and fields
+// tagged secret are redacted when set.
+func Print(w io.Writer, cfg any) error {
+	data, err := json.MarshalIndent(effective(reflect.ValueOf(cfg)), "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot encode config: %w", err)
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// object is a JSON object keeping the order of its fields.
+type object []member
+
+type member struct {
+	key   string
+	value any
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, err := json.Marshal(m.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// effective converts v into a value printed in the config file format.
+func effective(v reflect.Value) any {
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+	if raw, ok := v.Interface().(json.RawMessage); ok {
+		if len(raw) == 0 {
+			return nil
+		}
+		return raw
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return effective(v.Elem())
+	case reflect.Struct:
+		var o object
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			key, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+			if key == "-" || !sf.IsExported() {
+				continue
+			}
+			if key == "" {
+				key = sf.Name
+			}
+
+			fv := v.Field(i)
+			if sf.Tag.Get("secret") == "true" && !fv.IsZero() {
+				o = append(o, member{key: key, value: redacted})
+				continue
+			}
+			o = append(o, member{key: key, value: effective(fv)})
+		}
+		return o
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		list := make([]any, v.Len())
+		for i := range list {
+			list[i] = effective(v.Index(i))
+		}
+		return list
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		m := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m[fmt.Sprint(iter.Key().Interface())] = effective(iter.Value())
+		}
+		return m
+	}
+	return v.Interface()
+}
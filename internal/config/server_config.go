@@ -2,148 +2,112 @@ package config
 
 import (
 	"flag"
-	"fmt"
 	"os"
-	"strconv"
 	"time"
 )
 
 // ServerConfig stores server configuration parameters.
+// See load for how the tags map fields to the config file, env and flags.
 type ServerConfig struct {
 	// Addr is the server address in the form host:port.
-	Addr string `env:"ADDRESS" json:"address"`
+	Addr string `json:"address" env:"ADDRESS" flag:"a" usage:"server address"`
 
 	// StoreInterval defines the interval for saving metrics to persistent storage.
 	// A zero value means synchronous saving.
-	StoreInterval time.Duration `env:"STORE_INTERVAL" json:"store_interval"`
+	StoreInterval time.Duration `json:"store_interval" env:"STORE_INTERVAL" flag:"i" usage:"metrics store interval(0 to sync)"`
 
 	// FileStoragePath is the path to the file used for storing metrics.
-	FileStoragePath string `env:"FILE_STORAGE_PATH" json:"store_file"`
+	FileStoragePath string `json:"store_file" env:"FILE_STORAGE_PATH" flag:"f" usage:"path of storage file"`
 
 	// Restore enables or disables restoring metrics on startup.
-	Restore bool `env:"RESTORE" json:"restore"`
+	Restore bool `json:"restore" env:"RESTORE" flag:"r" usage:"boolean to load/not saved values"`
 
 	// DatabaseDSN is the data source name for connecting to the database.
-	DatabaseDSN string `env:"DATABASE_DSN" json:"database_dsn"`
+	DatabaseDSN string `json:"database_dsn" env:"DATABASE_DSN" flag:"d" usage:"database DSN" secret:"true"`
 
 	// Key is an optional key used for SHA256 request signing and verification.
-	Key string `env:"KEY" json:"-"`
+	Key string `json:"key" env:"KEY" flag:"k" usage:"SHA256 key" secret:"true"`
 
 	// AuditFile defines the file path for audit event logging.
-	AuditFile string `env:"AUDIT_FILE" json:"-"`
+	AuditFile string `json:"audit_file" env:"AUDIT_FILE" flag:"audit-file" usage:"audit file path"`
 
 	// AuditURL defines the HTTP endpoint for sending audit events.
-	AuditURL string `env:"AUDIT_URL" json:"-"`
+	AuditURL string `json:"audit_url" env:"AUDIT_URL" flag:"audit-url" usage:"audit url"`
 
 	// CryptoKey is the path to private key file
-	CryptoKey string `env:"CRYPTO_KEY" json:"crypto_key"`
+	CryptoKey string `json:"crypto_key" env:"CRYPTO_KEY" flag:"crypto-key" usage:"crypto key filepath"`
 
-	// ConfigPath is the path to json config file
-	ConfigPath string `env:"CONFIG" json:"-"`
+	// ConfigPath is the path to config file
+	ConfigPath string `json:"-" env:"CONFIG" flag:"c,config" usage:"config file path, JSON, YAML or TOML by extension"`
+
+	// PrintConfig makes the server print the effective configuration and exit.
+	PrintConfig bool `json:"-" flag:"print-config" usage:"print the effective configuration and exit"`
 
 	// TrustedSubnet is the string representation of allowed subnet
-	TrustedSubnet string `env:"TRUSTED_SUBNET" json:"trusted_subnet"`
+	TrustedSubnet string `json:"trusted_subnet" env:"TRUSTED_SUBNET" flag:"t" usage:"allowed subnet for metrics update"`
 
 	// GRPCAddr is gRPC server listen address
-	GRPCAddr string `env:"GRPC_ADDRESS" json:"-"`
+	GRPCAddr string `json:"address_grpc" env:"GRPC_ADDRESS" flag:"g" usage:"gRPC listen address"`
+
+	// IdempotencyWindow defines how long results of batch updates are remembered by idempotency key.
+	// A zero value disables idempotency handling.
+	IdempotencyWindow time.Duration `json:"idempotency_window" env:"IDEMPOTENCY_WINDOW" flag:"idempotency-window" usage:"how long batch results are remembered by idempotency key(0 to disable)"`
 }
 
 // LoadServerConfig loads and initializes the server configuration.
 func LoadServerConfig() (*ServerConfig, error) {
-	cfg := &ServerConfig{
-		Addr:            "localhost:8080",
-		StoreInterval:   300 * time.Second,
-		FileStoragePath: "tmp/metrics-db.json",
-		Restore:         true,
-		Key:             "",
-		AuditFile:       "",
-		AuditURL:        "",
-		CryptoKey:       "",
-		ConfigPath:      "",
-		TrustedSubnet:   "",
-		GRPCAddr:        "localhost:3200",
-	}
-
-	cfg.ConfigPath = os.Getenv("CONFIG")
-	if p, ok := findConfigPath(os.Args[1:]); ok {
-		cfg.ConfigPath = p
-	}
-
-	if cfg.ConfigPath != "" {
-		if err := applyServerJSON(cfg, cfg.ConfigPath); err != nil {
-			return nil, fmt.Errorf("cannot apply json config: %w", err)
-		}
-	}
-
-	flag.StringVar(&cfg.Addr, "a", cfg.Addr, "server address")
-	flag.DurationVar(&cfg.StoreInterval, "i", cfg.StoreInterval, "metrics store interval(0 to sync)")
-	flag.StringVar(&cfg.FileStoragePath, "f", cfg.FileStoragePath, "path of storage file")
-	flag.BoolVar(&cfg.Restore, "r", cfg.Restore, "boolean to load/not saved values")
-	flag.StringVar(&cfg.DatabaseDSN, "d", cfg.DatabaseDSN, "database DSN")
-	flag.StringVar(&cfg.Key, "k", cfg.Key, "SHA256 key")
-	flag.StringVar(&cfg.AuditFile, "audit-file", cfg.AuditFile, "audit file path")
-	flag.StringVar(&cfg.AuditURL, "audit-url", cfg.AuditURL, "audit url")
-	flag.StringVar(&cfg.CryptoKey, "crypto-key", cfg.CryptoKey, "crypto key filepath")
-	flag.StringVar(&cfg.TrustedSubnet, "t", cfg.TrustedSubnet, "allowed subnet for metrics update")
-	flag.StringVar(&cfg.GRPCAddr, "g", cfg.GRPCAddr, "gRPC listen address")
-
-	flag.Parse()
-
-	if envAddr, ok := os.LookupEnv("ADDRESS"); ok {
-		cfg.Addr = envAddr
-	}
-
-	if envStoreInterval, ok := os.LookupEnv("STORE_INTERVAL"); ok {
-		_, err := strconv.Atoi(envStoreInterval)
-		if err == nil {
-			envStoreInterval = envStoreInterval + "s"
-		}
-		storeInterval, err := time.ParseDuration(envStoreInterval)
-		if err != nil {
-			return nil, fmt.Errorf("cannot convert env STORE_INTERVAL to duration value: %w", err)
-		}
-		cfg.StoreInterval = storeInterval
-	}
-
-	if envFileStoragePath, ok := os.LookupEnv("FILE_STORAGE_PATH"); ok {
-		cfg.FileStoragePath = envFileStoragePath
-	}
+	return loadServerConfig(os.Args[1:], flag.ExitOnError)
+}
 
-	if envRestore, ok := os.LookupEnv("RESTORE"); ok {
-		restore, err := strconv.ParseBool(envRestore)
-		if err != nil {
-			return nil, fmt.Errorf("cannot convert env RESTORE to boolean value: %w", err)
-		}
-		cfg.Restore = restore
-	}
+// ReloadServerConfig reads the server configuration again from the config
+// file, environment and command line. Unlike LoadServerConfig it never exits
+// the process, so a running server can keep its current configuration when
+// the new one is broken.
+func ReloadServerConfig() (*ServerConfig, error) {
+	return reloadServerConfig(os.Args[1:])
+}
 
-	if envDatabaseDSN, ok := os.LookupEnv("DATABASE_DSN"); ok {
-		cfg.DatabaseDSN = envDatabaseDSN
-	}
+func reloadServerConfig(args []string) (*ServerConfig, error) {
+	return loadServerConfig(args, flag.ContinueOnError)
+}
 
-	if envKey, ok := os.LookupEnv("KEY"); ok {
-		cfg.Key = envKey
+func loadServerConfig(args []string, handling flag.ErrorHandling) (*ServerConfig, error) {
+	cfg := defaultServerConfig()
+	if err := load(cfg, args, handling); err != nil {
+		return nil, err
 	}
 
-	if envAuditFile, ok := os.LookupEnv("AUDIT_FILE"); ok {
-		cfg.AuditFile = envAuditFile
+	if err := cfg.Validate(); err != nil {
+		return nil, err
 	}
 
-	if envAuditURL, ok := os.LookupEnv("AUDIT_URL"); ok {
-		cfg.AuditURL = envAuditURL
-	}
+	return cfg, nil
+}
 
-	if envCryptoKey, ok := os.LookupEnv("CRYPTO_KEY"); ok {
-		cfg.CryptoKey = envCryptoKey
+// defaultServerConfig returns the configuration used when nothing is set.
+func defaultServerConfig() *ServerConfig {
+	return &ServerConfig{
+		Addr:              "localhost:8080",
+		StoreInterval:     300 * time.Second,
+		FileStoragePath:   "tmp/metrics-db.json",
+		Restore:           true,
+		GRPCAddr:          "localhost:3200",
+		IdempotencyWindow: 5 * time.Minute,
 	}
+}
 
-	if envTrustedSubnet, ok := os.LookupEnv("TRUSTED_SUBNET"); ok {
-		cfg.TrustedSubnet = envTrustedSubnet
-	}
+// Validate reports every setting the server cannot run with as a *ValidationError.
+func (cfg *ServerConfig) Validate() error {
+	var v validator
 
-	if envGRPCAddr, ok := os.LookupEnv("GRPC_ADDRESS"); ok {
-		cfg.GRPCAddr = envGRPCAddr
-	}
+	v.address("address", cfg.Addr)
+	v.check(cfg.Addr != "", "address", "must be set")
+	v.address("address_grpc", cfg.GRPCAddr)
+	v.nonNegative("store_interval", cfg.StoreInterval)
+	v.nonNegative("idempotency_window", cfg.IdempotencyWindow)
+	v.cidr("trusted_subnet", cfg.TrustedSubnet)
+	v.file("crypto_key", cfg.CryptoKey)
+	v.url("audit_url", cfg.AuditURL)
 
-	return cfg, nil
+	return v.err()
 }
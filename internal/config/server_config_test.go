@@ -0,0 +1,28 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloadServerConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.json")
+	t.Setenv("CONFIG", path)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"store_interval":"30s","trusted_subnet":"10.0.0.0/8"}`), 0o600))
+	cfg, err := reloadServerConfig(nil)
+	require.NoError(t, err)
+	require.Equal(t, 30*time.Second, cfg.StoreInterval)
+	require.Equal(t, "10.0.0.0/8", cfg.TrustedSubnet)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"trusted_subnet":"10.0.0.0"}`), 0o600))
+	_, err = reloadServerConfig(nil)
+	require.Error(t, err)
+
+	_, err = reloadServerConfig([]string{"-i", "-1s"})
+	require.Error(t, err)
+}
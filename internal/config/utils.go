@@ -1,163 +1,112 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
-	"os"
-	"strings"
+	"strconv"
 	"time"
 )
 
-func findConfigPath(args []string) (string, bool) {
-	for i := range args {
-		a := args[i]
-		if a == "-c" || a == "-config" {
-			if i+1 < len(args) {
-				return args[i+1], true
-			}
-			return "", false
-		}
-		if after, ok := strings.CutPrefix(a, "-c="); ok {
-			return after, true
-		}
-		if after, ok := strings.CutPrefix(a, "-config="); ok {
-			return after, true
-		}
+// parseInterval parses a duration such as "500ms", accepting a plain
+// integer as a number of seconds.
+func parseInterval(s string) (time.Duration, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		return time.Duration(n) * time.Second, nil
 	}
-	return "", false
+	return time.ParseDuration(s)
 }
 
-func applyServerJSON(cfg *ServerConfig, path string) error {
-	if path == "" {
-		return nil
+// parseJSONInterval parses a JSON duration string or an integer number of
+// seconds. ok is false for an empty string or null, which leave the interval unset.
+func parseJSONInterval(v json.RawMessage) (d time.Duration, ok bool, err error) {
+	if bytes.Equal(bytes.TrimSpace(v), []byte("null")) {
+		return 0, false, nil
 	}
 
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return fmt.Errorf("cannot read config file: %w", err)
+	var n int64
+	if err := json.Unmarshal(v, &n); err == nil {
+		return time.Duration(n) * time.Second, true, nil
 	}
 
-	var raw map[string]json.RawMessage
-	if err := json.Unmarshal(data, &raw); err != nil {
-		return fmt.Errorf("cannot parse config json: %w", err)
+	var s *string
+	if err := json.Unmarshal(v, &s); err != nil {
+		return 0, false, fmt.Errorf("must be a duration string or a number of seconds")
 	}
-
-	if v, ok := raw["address"]; ok {
-		var s string
-		if err := json.Unmarshal(v, &s); err == nil && s != "" {
-			cfg.Addr = s
-		}
+	if s == nil || *s == "" {
+		return 0, false, nil
 	}
 
-	if v, ok := raw["store_interval"]; ok {
-		var s string
-		if err := json.Unmarshal(v, &s); err != nil {
-			return fmt.Errorf("invalid store_interval: %w", err)
-		}
-		if s != "" {
-			d, err := time.ParseDuration(s)
-			if err != nil {
-				return fmt.Errorf("invalid store_interval: %w", err)
-			}
-			cfg.StoreInterval = d
-		}
-	}
-
-	if v, ok := raw["store_file"]; ok {
-		var s string
-		if err := json.Unmarshal(v, &s); err == nil && s != "" {
-			cfg.FileStoragePath = s
-		}
+	d, err = parseInterval(*s)
+	if err != nil {
+		return 0, false, err
 	}
+	return d, true, nil
+}
 
-	if v, ok := raw["restore"]; ok {
-		var b bool
-		if err := json.Unmarshal(v, &b); err != nil {
-			return fmt.Errorf("invalid restore: %w", err)
-		}
-		cfg.Restore = b
+func parseCollectors(v json.RawMessage) (map[string]CollectorConfig, error) {
+	var raw map[string]struct {
+		Enabled      *bool           `json:"enabled"`
+		PollInterval json.RawMessage `json:"poll_interval"`
+		Options      json.RawMessage `json:"options"`
 	}
-
-	if v, ok := raw["database_dsn"]; ok {
-		var s string
-		if err := json.Unmarshal(v, &s); err == nil && s != "" {
-			cfg.DatabaseDSN = s
-		}
+	if err := json.Unmarshal(v, &raw); err != nil {
+		return nil, err
 	}
 
-	if v, ok := raw["crypto_key"]; ok {
-		var s string
-		if err := json.Unmarshal(v, &s); err == nil && s != "" {
-			cfg.CryptoKey = s
-		}
-	}
-
-	if v, ok := raw["trusted_subnet"]; ok {
-		var s string
-		if err := json.Unmarshal(v, &s); err == nil && s != "" {
-			cfg.TrustedSubnet = s
+	collectors := make(map[string]CollectorConfig, len(raw))
+	for name, c := range raw {
+		cc := CollectorConfig{Enabled: c.Enabled, Options: c.Options}
+		if len(c.PollInterval) > 0 {
+			d, _, err := parseJSONInterval(c.PollInterval)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid poll_interval: %w", name, err)
+			}
+			cc.PollInterval = d
 		}
+		collectors[name] = cc
 	}
-
-	return nil
+	return collectors, nil
 }
 
-func applyAgentJSON(cfg *AgentConfig, path string) error {
-	if path == "" {
-		return nil
-	}
-
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return fmt.Errorf("cannot read config file: %w", err)
+func parseDestinations(v json.RawMessage) ([]DestinationConfig, error) {
+	var raw []struct {
+		DestinationConfig
+		RetryInitialBackoff json.RawMessage `json:"retry_initial_backoff"`
+		RetryMaxBackoff     json.RawMessage `json:"retry_max_backoff"`
 	}
-
-	var raw map[string]json.RawMessage
-	if err := json.Unmarshal(data, &raw); err != nil {
-		return fmt.Errorf("cannot parse config json: %w", err)
-	}
-
-	if v, ok := raw["address"]; ok {
-		var s string
-		if err := json.Unmarshal(v, &s); err == nil && s != "" {
-			cfg.Addr = s
-		}
+	if err := json.Unmarshal(v, &raw); err != nil {
+		return nil, err
 	}
 
-	if v, ok := raw["poll_interval"]; ok {
-		var s string
-		if err := json.Unmarshal(v, &s); err != nil {
-			return fmt.Errorf("invalid poll_interval: %w", err)
+	destinations := make([]DestinationConfig, 0, len(raw))
+	seen := make(map[string]bool)
+	for i, r := range raw {
+		d := r.DestinationConfig
+		if d.Name == "" {
+			d.Name = fmt.Sprintf("destination-%d", i+1)
 		}
-		if s != "" {
-			d, err := time.ParseDuration(s)
-			if err != nil {
-				return fmt.Errorf("invalid poll_interval: %w", err)
-			}
-			cfg.PollInterval = int(d.Seconds())
+		if seen[d.Name] {
+			return nil, fmt.Errorf("duplicate destination %q", d.Name)
 		}
-	}
+		seen[d.Name] = true
 
-	if v, ok := raw["report_interval"]; ok {
-		var s string
-		if err := json.Unmarshal(v, &s); err != nil {
-			return fmt.Errorf("invalid report_interval: %w", err)
+		if (d.Addr == "") == (d.GRPCAddr == "") {
+			return nil, fmt.Errorf("%s: exactly one of address and address_grpc must be set", d.Name)
 		}
-		if s != "" {
-			d, err := time.ParseDuration(s)
-			if err != nil {
-				return fmt.Errorf("invalid report_interval: %w", err)
+
+		var err error
+		if len(r.RetryInitialBackoff) > 0 {
+			if d.RetryInitialBackoff, _, err = parseJSONInterval(r.RetryInitialBackoff); err != nil {
+				return nil, fmt.Errorf("%s: invalid retry_initial_backoff: %w", d.Name, err)
 			}
-			cfg.ReportInterval = int(d.Seconds())
 		}
-	}
-
-	if v, ok := raw["crypto_key"]; ok {
-		var s string
-		if err := json.Unmarshal(v, &s); err == nil && s != "" {
-			cfg.CryptoKey = s
+		if len(r.RetryMaxBackoff) > 0 {
+			if d.RetryMaxBackoff, _, err = parseJSONInterval(r.RetryMaxBackoff); err != nil {
+				return nil, fmt.Errorf("%s: invalid retry_max_backoff: %w", d.Name, err)
+			}
 		}
+		destinations = append(destinations, d)
 	}
-
-	return nil
+	return destinations, nil
 }
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// FieldError describes one invalid setting.
+type FieldError struct {
+	// Field is the config file key of the setting.
+	Field string
+
+	// Message tells what is wrong with it.
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationError lists every invalid setting of a configuration.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Error()
+	}
+	return "invalid config: " + strings.Join(msgs, "; ")
+}
+
+// validator collects the problems found in a configuration.
+type validator struct {
+	fields []FieldError
+}
+
+func (v *validator) fail(field, format string, args ...any) {
+	v.fields = append(v.fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) check(ok bool, field, format string, args ...any) {
+	if !ok {
+		v.fail(field, format, args...)
+	}
+}
+
+func (v *validator) positive(field string, d time.Duration) {
+	v.check(d > 0, field, "must be positive, got %s", d)
+}
+
+func (v *validator) nonNegative(field string, d time.Duration) {
+	v.check(d >= 0, field, "must not be negative, got %s", d)
+}
+
+// address checks a host:port address. Empty addresses are not checked.
+func (v *validator) address(field, addr string) {
+	if addr == "" {
+		return
+	}
+	if _, port, err := net.SplitHostPort(addr); err != nil || port == "" {
+		v.fail(field, "invalid address %q, want host:port", addr)
+	}
+}
+
+// addresses checks a comma separated list of host:port addresses.
+func (v *validator) addresses(field, addrs string) {
+	if addrs == "" {
+		return
+	}
+	for _, a := range strings.Split(addrs, ",") {
+		v.address(field, strings.TrimSpace(a))
+	}
+}
+
+func (v *validator) cidr(field, s string) {
+	if s == "" {
+		return
+	}
+	if _, _, err := net.ParseCIDR(s); err != nil {
+		v.fail(field, "invalid CIDR %q", s)
+	}
+}
+
+// file checks that path names an existing regular file. Empty paths are not checked.
+func (v *validator) file(field, path string) {
+	if path == "" {
+		return
+	}
+	fi, err := os.Stat(path)
+	switch {
+	case err != nil:
+		v.fail(field, "cannot access %q: %s", path, err)
+	case fi.IsDir():
+		v.fail(field, "%q is a directory", path)
+	}
+}
+
+func (v *validator) url(field, s string) {
+	if s == "" {
+		return
+	}
+	u, err := url.ParseRequestURI(s)
+	if err != nil || u.Host == "" {
+		v.fail(field, "invalid URL %q", s)
+	}
+}
+
+func (v *validator) err() error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: v.fields}
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// WatchFile calls onChange whenever the modification time or size of the
+// file at path changes, checking every interval until ctx is done.
+// A file that cannot be read is treated as unchanged, so a config being
+// rewritten does not trigger a reload until it is back in place.
+func WatchFile(ctx context.Context, path string, interval time.Duration, onChange func()) {
+	last, _ := os.Stat(path)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fi, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			if last == nil || !fi.ModTime().Equal(last.ModTime()) || fi.Size() != last.Size() {
+				last = fi
+				onChange()
+			}
+		}
+	}
+}
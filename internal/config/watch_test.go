@@ -0,0 +1,40 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchFile_NotifiesOnChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{}`), 0o600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var changes atomic.Int32
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		WatchFile(ctx, path, 5*time.Millisecond, func() { changes.Add(1) })
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	require.Zero(t, changes.Load())
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"report_interval":"5s"}`), 0o600))
+	require.Eventually(t, func() bool { return changes.Load() == 1 }, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, os.Remove(path))
+	time.Sleep(20 * time.Millisecond)
+	require.Equal(t, int32(1), changes.Load())
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,26 @@
+package cryptography
+
+import "sync/atomic"
+
+// HashKey holds the key used for HMAC-SHA256 request verification.
+// It can be replaced while requests are being verified.
+type HashKey struct {
+	key atomic.Pointer[string]
+}
+
+// NewHashKey creates a HashKey holding key.
+func NewHashKey(key string) *HashKey {
+	k := &HashKey{}
+	k.Set(key)
+	return k
+}
+
+// Set replaces the key.
+func (k *HashKey) Set(key string) {
+	k.key.Store(&key)
+}
+
+// Get returns the current key.
+func (k *HashKey) Get() string {
+	return *k.key.Load()
+}
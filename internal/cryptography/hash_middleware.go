@@ -5,16 +5,15 @@ import (
 	"encoding/hex"
 	"io"
 	"net/http"
-
-	"github.com/JinFuuMugen/ya_go_metrics/internal/config"
 )
 
 // ValidateHashMiddleware returns an HTTP middleware that validates the HMAC-SHA256 hash of incoming requests.
 // When a secret key is configured, the middleware also adds a
-// "HashSHA256" header to the response.
-func ValidateHashMiddleware(cfg *config.ServerConfig) func(next http.Handler) http.Handler {
+// "HashSHA256" header to the response. Changes of key apply to the following requests.
+func ValidateHashMiddleware(key *HashKey) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			k := key.Get()
 
 			hashString := r.Header.Get("HashSHA256")
 			if hashString != "" {
@@ -26,7 +25,7 @@ func ValidateHashMiddleware(cfg *config.ServerConfig) func(next http.Handler) ht
 
 				newBody := io.NopCloser(bytes.NewReader(body))
 
-				hash := GetHMACSHA256(body, cfg.Key)
+				hash := GetHMACSHA256(body, k)
 				calculatedHashString := hex.EncodeToString(hash)
 
 				if hashString != calculatedHashString {
@@ -39,8 +38,8 @@ func ValidateHashMiddleware(cfg *config.ServerConfig) func(next http.Handler) ht
 
 			next.ServeHTTP(w, r)
 
-			if cfg.Key != "" {
-				responseHash := GetHMACSHA256([]byte(""), cfg.Key)
+			if k != "" {
+				responseHash := GetHMACSHA256([]byte(""), k)
 				responseHashString := hex.EncodeToString(responseHash)
 				w.Header().Set("HashSHA256", responseHashString)
 			}
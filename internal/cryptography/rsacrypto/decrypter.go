@@ -0,0 +1,36 @@
+package rsacrypto
+
+import (
+	"crypto/rsa"
+	"errors"
+	"sync/atomic"
+)
+
+var errNoPrivateKey = errors.New("no private key configured")
+
+// Decrypter decrypts request bodies with a private key that can be
+// replaced while requests are being decrypted.
+type Decrypter struct {
+	key atomic.Pointer[rsa.PrivateKey]
+}
+
+// NewDecrypter creates a Decrypter using priv. A nil key rejects every encrypted body.
+func NewDecrypter(priv *rsa.PrivateKey) *Decrypter {
+	d := &Decrypter{}
+	d.SetKey(priv)
+	return d
+}
+
+// SetKey replaces the private key.
+func (d *Decrypter) SetKey(priv *rsa.PrivateKey) {
+	d.key.Store(priv)
+}
+
+// Decrypt decrypts data with the current private key.
+func (d *Decrypter) Decrypt(data []byte) ([]byte, error) {
+	priv := d.key.Load()
+	if priv == nil {
+		return nil, errNoPrivateKey
+	}
+	return Decrypt(priv, data)
+}
@@ -0,0 +1,124 @@
+package rsacrypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Payloads are encrypted with a random AES-256-GCM key per message, and only
+// that key is encrypted with RSA-OAEP, so payloads of any size fit. An
+// envelope is laid out as
+//
+//	magic   [4]byte  "YGME"
+//	version uint8    1
+//	keyLen  uint16   length of the encrypted key, big endian
+//	key     [keyLen]byte  AES key encrypted with RSA-OAEP SHA-256
+//	nonce   [12]byte GCM nonce
+//	data    []byte   payload encrypted with AES-GCM, followed by the tag
+//
+// Everything before data is authenticated as GCM additional data.
+
+const (
+	envelopeMagic   = "YGME"
+	envelopeVersion = 1
+
+	aesKeySize = 32
+	nonceSize  = 12
+
+	// headerSize is the size of the header fields before the encrypted key.
+	headerSize = len(envelopeMagic) + 1 + 2
+)
+
+var (
+	// ErrMalformedEnvelope is returned when data is not an envelope.
+	ErrMalformedEnvelope = errors.New("malformed envelope")
+
+	// ErrUnsupportedVersion is returned for envelopes of an unknown format version.
+	ErrUnsupportedVersion = errors.New("unsupported envelope version")
+)
+
+// Encrypt encrypts data of any size for the owner of pub.
+func Encrypt(pub *rsa.PublicKey, data []byte) ([]byte, error) {
+	key := make([]byte, aesKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, fmt.Errorf("cannot generate key: %w", err)
+	}
+
+	encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, key, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encrypt key: %w", err)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	size := headerSize + len(encryptedKey) + nonceSize
+	out := make([]byte, size, size+len(data)+gcm.Overhead())
+	copy(out, envelopeMagic)
+	out[len(envelopeMagic)] = envelopeVersion
+	binary.BigEndian.PutUint16(out[len(envelopeMagic)+1:], uint16(len(encryptedKey)))
+	copy(out[headerSize:], encryptedKey)
+
+	nonce := out[headerSize+len(encryptedKey):]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("cannot generate nonce: %w", err)
+	}
+
+	// The header is copied as additional data must not overlap dst.
+	return gcm.Seal(out, nonce, data, bytes.Clone(out)), nil
+}
+
+// Decrypt decrypts an envelope created by Encrypt.
+func Decrypt(priv *rsa.PrivateKey, data []byte) ([]byte, error) {
+	if len(data) < headerSize || string(data[:len(envelopeMagic)]) != envelopeMagic {
+		return nil, ErrMalformedEnvelope
+	}
+	if v := data[len(envelopeMagic)]; v != envelopeVersion {
+		return nil, fmt.Errorf("%w %d", ErrUnsupportedVersion, v)
+	}
+
+	keyLen := int(binary.BigEndian.Uint16(data[len(envelopeMagic)+1:]))
+	header := headerSize + keyLen + nonceSize
+	if len(data) < header {
+		return nil, ErrMalformedEnvelope
+	}
+
+	key, err := rsa.DecryptOAEP(sha256.New(), nil, priv, data[headerSize:headerSize+keyLen], nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt key: %w", err)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := data[headerSize+keyLen : header]
+	plain, err := gcm.Open(nil, nonce, data[header:], data[:header])
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt payload: %w", err)
+	}
+	return plain, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create gcm: %w", err)
+	}
+	return gcm, nil
+}
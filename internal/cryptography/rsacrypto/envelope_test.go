@@ -0,0 +1,112 @@
+package rsacrypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+)
+
+func randomPayload(t *testing.T, size int) []byte {
+	t.Helper()
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatalf("failed to generate payload: %v", err)
+	}
+	return data
+}
+
+func TestEncryptDecrypt_PayloadSizes(t *testing.T) {
+	priv, pub := generateTestKeys(t)
+
+	// 190 bytes is the most RSA-OAEP SHA-256 fits in a 2048-bit block and
+	// 245 the most PKCS #1 v1.5 did, every agent report is larger.
+	sizes := []int{0, 1, 190, 245, 246, 4 << 10, 64 << 10, 1 << 20, 4 << 20}
+
+	for _, size := range sizes {
+		data := randomPayload(t, size)
+
+		encrypted, err := Encrypt(pub, data)
+		if err != nil {
+			t.Fatalf("size %d: encrypt failed: %v", size, err)
+		}
+		if overhead := len(encrypted) - size; overhead != headerSize+pub.Size()+nonceSize+16 {
+			t.Fatalf("size %d: unexpected envelope overhead %d", size, overhead)
+		}
+
+		decrypted, err := Decrypt(priv, encrypted)
+		if err != nil {
+			t.Fatalf("size %d: decrypt failed: %v", size, err)
+		}
+		if !bytes.Equal(decrypted, data) {
+			t.Fatalf("size %d: decrypted payload mismatch", size)
+		}
+	}
+}
+
+func TestEncrypt_UsesFreshKeyPerMessage(t *testing.T) {
+	_, pub := generateTestKeys(t)
+	data := randomPayload(t, 1024)
+
+	first, err := Encrypt(pub, data)
+	if err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+	second, err := Encrypt(pub, data)
+	if err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+
+	keyEnd := headerSize + pub.Size()
+	if bytes.Equal(first[headerSize:keyEnd], second[headerSize:keyEnd]) {
+		t.Fatal("encrypted keys of two messages are equal")
+	}
+	if bytes.Equal(first[keyEnd:], second[keyEnd:]) {
+		t.Fatal("ciphertexts of two messages are equal")
+	}
+}
+
+func TestDecrypt_Rejects(t *testing.T) {
+	priv, pub := generateTestKeys(t)
+	otherPriv, _ := generateTestKeys(t)
+
+	encrypted, err := Encrypt(pub, randomPayload(t, 64<<10))
+	if err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+
+	modified := func(i int, b byte) []byte {
+		data := bytes.Clone(encrypted)
+		data[i] = b
+		return data
+	}
+
+	tests := []struct {
+		name string
+		priv *rsa.PrivateKey
+		data []byte
+		want error
+	}{
+		{name: "empty", priv: priv, data: nil, want: ErrMalformedEnvelope},
+		{name: "legacy pkcs1 block", priv: priv, data: randomPayload(t, pub.Size()), want: ErrMalformedEnvelope},
+		{name: "truncated header", priv: priv, data: encrypted[:headerSize+10], want: ErrMalformedEnvelope},
+		{name: "unknown version", priv: priv, data: modified(len(envelopeMagic), 2), want: ErrUnsupportedVersion},
+		{name: "wrong key", priv: otherPriv, data: encrypted},
+		{name: "modified nonce", priv: priv, data: modified(headerSize+pub.Size(), encrypted[headerSize+pub.Size()]^1)},
+		{name: "modified payload", priv: priv, data: modified(len(encrypted)-100, encrypted[len(encrypted)-100]^1)},
+		{name: "truncated tag", priv: priv, data: encrypted[:len(encrypted)-1]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decrypt(tt.priv, tt.data)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
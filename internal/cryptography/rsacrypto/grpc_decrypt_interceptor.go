@@ -0,0 +1,49 @@
+package rsacrypto
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// envelopeMessage is a request that may carry an encrypted copy of itself.
+type envelopeMessage interface {
+	proto.Message
+	GetEnvelope() []byte
+}
+
+// DecryptUnaryInterceptor is the gRPC counterpart of DecryptMiddleware.
+// Requests with an envelope are replaced by the request decrypted from it
+// with the current key of d, other requests are passed on unchanged.
+func DecryptUnaryInterceptor(d *Decrypter) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+
+		msg, ok := req.(envelopeMessage)
+		if !ok || len(msg.GetEnvelope()) == 0 {
+			return handler(ctx, req)
+		}
+
+		data, err := d.Decrypt(msg.GetEnvelope())
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "decrypt failed")
+		}
+
+		decrypted := msg.ProtoReflect().New().Interface()
+		if err := proto.Unmarshal(data, decrypted); err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid encrypted request")
+		}
+		if len(decrypted.(envelopeMessage).GetEnvelope()) > 0 {
+			return nil, status.Error(codes.InvalidArgument, "nested envelope")
+		}
+
+		return handler(ctx, decrypted)
+	}
+}
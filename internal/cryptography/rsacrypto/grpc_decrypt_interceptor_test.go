@@ -0,0 +1,108 @@
+package rsacrypto
+
+import (
+	"context"
+	"crypto/rsa"
+	"fmt"
+	"testing"
+
+	pb "github.com/JinFuuMugen/ya_go_metrics/internal/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func testRequest(n int) *pb.UpdateMetricsRequest {
+	req := &pb.UpdateMetricsRequest{}
+	for i := 0; i < n; i++ {
+		req.Metrics = append(req.Metrics, &pb.Metric{
+			Id:    fmt.Sprintf("Gauge%d", i),
+			Type:  pb.Metric_GAUGE,
+			Value: float64(i) * 1.5,
+		})
+	}
+	return req
+}
+
+func sealRequest(t *testing.T, pub *rsa.PublicKey, req *pb.UpdateMetricsRequest) *pb.UpdateMetricsRequest {
+	t.Helper()
+	data, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	envelope, err := Encrypt(pub, data)
+	if err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+	return &pb.UpdateMetricsRequest{Envelope: envelope}
+}
+
+func TestDecryptUnaryInterceptor(t *testing.T) {
+	priv, pub := generateTestKeys(t)
+	ic := DecryptUnaryInterceptor(NewDecrypter(priv))
+	info := &grpc.UnaryServerInfo{FullMethod: "/metrics.Metrics/UpdateMetrics"}
+
+	var got any
+	handler := func(ctx context.Context, req any) (any, error) {
+		got = req
+		return &pb.UpdateMetricsResponse{}, nil
+	}
+
+	for _, n := range []int{30, 1000, 50000} {
+		want := testRequest(n)
+
+		if _, err := ic(context.Background(), sealRequest(t, pub, want), info, handler); err != nil {
+			t.Fatalf("%d metrics: unexpected error: %v", n, err)
+		}
+		if !proto.Equal(got.(proto.Message), want) {
+			t.Fatalf("%d metrics: decrypted request mismatch", n)
+		}
+	}
+
+	plain := testRequest(10)
+	if _, err := ic(context.Background(), plain, info, handler); err != nil {
+		t.Fatalf("plain request: unexpected error: %v", err)
+	}
+	if got != plain {
+		t.Fatal("plain request must be passed on unchanged")
+	}
+}
+
+func TestDecryptUnaryInterceptor_Rejects(t *testing.T) {
+	priv, pub := generateTestKeys(t)
+	_, otherPub := generateTestKeys(t)
+	info := &grpc.UnaryServerInfo{FullMethod: "/metrics.Metrics/UpdateMetrics"}
+
+	handler := func(ctx context.Context, req any) (any, error) {
+		t.Fatal("handler should not be called")
+		return nil, nil
+	}
+
+	notProto, err := Encrypt(pub, []byte{0xff, 0xff, 0xff})
+	if err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		priv *rsa.PrivateKey
+		req  *pb.UpdateMetricsRequest
+	}{
+		{name: "garbage", priv: priv, req: &pb.UpdateMetricsRequest{Envelope: []byte("garbage")}},
+		{name: "other key", priv: priv, req: sealRequest(t, otherPub, testRequest(100))},
+		{name: "no private key", priv: nil, req: sealRequest(t, pub, testRequest(100))},
+		{name: "not a request", priv: priv, req: &pb.UpdateMetricsRequest{Envelope: notProto}},
+		{name: "nested envelope", priv: priv, req: sealRequest(t, pub, sealRequest(t, pub, testRequest(1)))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ic := DecryptUnaryInterceptor(NewDecrypter(tt.priv))
+			_, err := ic(context.Background(), tt.req, info, handler)
+			if status.Code(err) != codes.InvalidArgument {
+				t.Fatalf("expected InvalidArgument, got %v", err)
+			}
+		})
+	}
+}
@@ -1,7 +1,6 @@
 package rsacrypto
 
 import (
-	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
@@ -48,13 +47,3 @@ func LoadPrivateKey(path string) (*rsa.PrivateKey, error) {
 
 	return x509.ParsePKCS1PrivateKey(block.Bytes)
 }
-
-// Encrypt ecrypts data using EncryptPKCS1v15
-func Encrypt(pub *rsa.PublicKey, data []byte) ([]byte, error) {
-	return rsa.EncryptPKCS1v15(rand.Reader, pub, data)
-}
-
-// Decrypt decrypts data using DecryptPKCS1v15
-func Decrypt(priv *rsa.PrivateKey, data []byte) ([]byte, error) {
-	return rsa.DecryptPKCS1v15(rand.Reader, priv, data)
-}
@@ -9,6 +9,11 @@ import (
 
 // CryptoMiddleware decrypts request body
 func CryptoMiddleware(privateKey *rsa.PrivateKey) func(http.Handler) http.Handler {
+	return DecryptMiddleware(NewDecrypter(privateKey))
+}
+
+// DecryptMiddleware decrypts request body with the current key of d.
+func DecryptMiddleware(d *Decrypter) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
@@ -23,7 +28,7 @@ func CryptoMiddleware(privateKey *rsa.PrivateKey) func(http.Handler) http.Handle
 				return
 			}
 
-			decrypted, err := Decrypt(privateKey, encryptedBody)
+			decrypted, err := d.Decrypt(encryptedBody)
 			if err != nil {
 				http.Error(w, "decrypt failed", http.StatusBadRequest)
 				return
@@ -2,8 +2,10 @@ package rsacrypto
 
 import (
 	"bytes"
+	"compress/gzip"
 	"crypto/rand"
 	"crypto/rsa"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -108,3 +110,100 @@ func TestCryptoMiddleware_EmptyBody(t *testing.T) {
 		t.Fatalf("expected 400, got %d", rec.Code)
 	}
 }
+
+func TestDecryptMiddleware_KeyReplaced(t *testing.T) {
+	oldPriv, oldPub := generateTestKeys(t)
+	newPriv, newPub := generateTestKeys(t)
+
+	d := NewDecrypter(oldPriv)
+	handler := DecryptMiddleware(d)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	}))
+
+	send := func(pub *rsa.PublicKey) int {
+		encrypted, err := Encrypt(pub, []byte("secret payload"))
+		if err != nil {
+			t.Fatalf("encrypt failed: %v", err)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(encrypted))
+		req.Header.Set("X-Encrypted", "rsa")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := send(oldPub); code != http.StatusOK {
+		t.Fatalf("expected 200 with old key, got %d", code)
+	}
+
+	d.SetKey(newPriv)
+
+	if code := send(oldPub); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for body encrypted with replaced key, got %d", code)
+	}
+	if code := send(newPub); code != http.StatusOK {
+		t.Fatalf("expected 200 with new key, got %d", code)
+	}
+
+	d.SetKey(nil)
+
+	if code := send(newPub); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without key, got %d", code)
+	}
+}
+
+// testReport returns a gzip compressed JSON batch of n gauges, like the agent sends.
+func testReport(t *testing.T, n int) []byte {
+	t.Helper()
+
+	var raw bytes.Buffer
+	raw.WriteByte('[')
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			raw.WriteByte(',')
+		}
+		fmt.Fprintf(&raw, `{"id":"Gauge%d","type":"gauge","value":%d.123456}`, i, i*7919)
+	}
+	raw.WriteByte(']')
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err := zw.Write(raw.Bytes()); err != nil {
+		t.Fatalf("gzip failed: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("gzip failed: %v", err)
+	}
+	return compressed.Bytes()
+}
+
+func TestCryptoMiddleware_DecryptsReports(t *testing.T) {
+	priv, pub := generateTestKeys(t)
+
+	handler := CryptoMiddleware(priv)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	}))
+
+	for _, n := range []int{30, 1000, 50000} {
+		report := testReport(t, n)
+		encrypted, err := Encrypt(pub, report)
+		if err != nil {
+			t.Fatalf("%d metrics: encrypt failed: %v", n, err)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(encrypted))
+		req.Header.Set("X-Encrypted", "rsa")
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%d metrics (%d bytes): unexpected status code: %d", n, len(report), rec.Code)
+		}
+		if !bytes.Equal(rec.Body.Bytes(), report) {
+			t.Fatalf("%d metrics: decrypted body mismatch", n)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+// Package fanout sends agent reports to several destinations independently.
+package fanout
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/idempotency"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/logger"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/retry"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/sender"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/spool"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
+)
+
+// DefaultMaxPending is the default number of reports kept per destination
+// while it is unreachable.
+const DefaultMaxPending = 64
+
+// Destination is a named Sender reports are fanned out to.
+type Destination struct {
+	// Name identifies the destination in logs.
+	Name string
+
+	// Sender delivers reports to the destination with its own retry policy.
+	Sender sender.Sender
+}
+
+type destination struct {
+	Destination
+
+	mu       sync.Mutex
+	pending  []spool.Batch
+	inflight bool
+	wake     chan struct{}
+}
+
+// Sender hands every report to all destinations.
+//
+// Each destination has its own queue and goroutine, so a slow or dead
+// destination never delays the others or the caller. Undelivered reports stay
+// queued, with their idempotency keys, and are retried on the next report;
+// when a queue is full its oldest reports never sent are merged.
+type Sender struct {
+	dests      []*destination
+	maxPending int
+	stop       chan struct{}
+	wg         sync.WaitGroup
+	closeOnce  sync.Once
+}
+
+// New starts delivering to dests. maxPending limits the reports queued per
+// destination, DefaultMaxPending if not positive.
+func New(dests []Destination, maxPending int) *Sender {
+	if maxPending <= 0 {
+		maxPending = DefaultMaxPending
+	}
+
+	s := &Sender{maxPending: maxPending, stop: make(chan struct{})}
+	for _, d := range dests {
+		dest := &destination{Destination: d, wake: make(chan struct{}, 1)}
+		s.dests = append(s.dests, dest)
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.run(dest)
+		}()
+	}
+	return s
+}
+
+// Process queues the metrics for every destination.
+func (s *Sender) Process(counters []storage.Counter, gauges []storage.Gauge) error {
+	return s.ProcessWithKey(idempotency.NewKey(), counters, gauges)
+}
+
+// ProcessWithKey is like Process but uses the given idempotency key.
+// It never fails: delivery is owned by the destinations from now on.
+func (s *Sender) ProcessWithKey(key string, counters []storage.Counter, gauges []storage.Gauge) error {
+	b := spool.Batch{Key: key, Created: time.Now(), Counters: counters, Gauges: gauges}
+
+	for _, d := range s.dests {
+		d.push(b, s.maxPending)
+
+		select {
+		case d.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Compress delegates to the first destination.
+func (s *Sender) Compress(data []byte) ([]byte, error) {
+	if len(s.dests) == 0 {
+		return data, nil
+	}
+	return s.dests[0].Sender.Compress(data)
+}
+
+// Pending returns the number of reports queued for the named destination.
+func (s *Sender) Pending(name string) int {
+	for _, d := range s.dests {
+		if d.Name == name {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			return len(d.pending)
+		}
+	}
+	return 0
+}
+
+// Close makes one last delivery attempt per destination and waits for it
+// until ctx is done.
+func (s *Sender) Close(ctx context.Context) error {
+	s.closeOnce.Do(func() { close(s.stop) })
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	var errs []error
+	for _, d := range s.dests {
+		if n := s.Pending(d.Name); n > 0 {
+			errs = append(errs, errors.New(d.Name+": reports left undelivered"))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Sender) run(d *destination) {
+	for {
+		select {
+		case <-d.wake:
+			d.drain()
+		case <-s.stop:
+			d.drain()
+			return
+		}
+	}
+}
+
+// push queues b. Over the limit the two oldest adjacent reports never handed
+// to the destination are merged, or the oldest report not being sent right now
+// is dropped if there are none. Attempted reports keep their own keys, as the
+// destination may have applied them already.
+func (d *destination) push(b spool.Batch, maxPending int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.pending = append(d.pending, b)
+	if len(d.pending) <= maxPending {
+		return
+	}
+
+	for i := 0; i+1 < len(d.pending); i++ {
+		if spool.CanMerge(d.pending[i], d.pending[i+1]) {
+			d.pending[i] = spool.Merge(d.pending[i], d.pending[i+1])
+			d.pending = append(d.pending[:i+1], d.pending[i+2:]...)
+			return
+		}
+	}
+
+	i := 0
+	if d.inflight {
+		i = 1
+	}
+	logger.Warnf("destination %s queue is full, dropping report %s", d.Name, d.pending[i].Key)
+	d.pending = append(d.pending[:i], d.pending[i+1:]...)
+}
+
+// drain sends queued reports in order until one fails.
+func (d *destination) drain() {
+	for {
+		d.mu.Lock()
+		if len(d.pending) == 0 {
+			d.mu.Unlock()
+			return
+		}
+		d.pending[0].Attempted = true
+		b := d.pending[0]
+		d.inflight = true
+		d.mu.Unlock()
+
+		err := d.Sender.ProcessWithKey(b.Key, b.Counters, b.Gauges)
+
+		d.mu.Lock()
+		d.inflight = false
+		if err == nil || retry.IsPermanent(err) {
+			d.pending = d.pending[1:]
+		}
+		d.mu.Unlock()
+
+		if err != nil && !retry.IsPermanent(err) {
+			logger.Warnf("destination %s unavailable, report kept for the next attempt: %s", d.Name, err)
+			return
+		}
+		if err != nil {
+			logger.Errorf("destination %s rejected report, dropping it: %s", d.Name, err)
+		}
+	}
+}
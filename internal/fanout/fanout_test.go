@@ -0,0 +1,158 @@
+package fanout
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/logger"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/retry"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/spool"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSender struct {
+	mu    sync.Mutex
+	err   error
+	block chan struct{}
+	keys  []string
+	sent  []int64
+}
+
+func (f *fakeSender) Process(counters []storage.Counter, gauges []storage.Gauge) error {
+	return f.ProcessWithKey("", counters, gauges)
+}
+
+func (f *fakeSender) ProcessWithKey(key string, counters []storage.Counter, _ []storage.Gauge) error {
+	if f.block != nil {
+		<-f.block
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return f.err
+	}
+	f.keys = append(f.keys, key)
+	for _, c := range counters {
+		f.sent = append(f.sent, c.Value)
+	}
+	return nil
+}
+
+func (f *fakeSender) Compress(b []byte) ([]byte, error) { return b, nil }
+
+func (f *fakeSender) delivered() []int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]int64(nil), f.sent...)
+}
+
+func (f *fakeSender) setErr(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+func report(v int64) []storage.Counter {
+	return []storage.Counter{{Name: "PollCount", Type: storage.MetricTypeCounter, Value: v}}
+}
+
+func TestSender_DeadDestinationDoesNotStallOthers(t *testing.T) {
+	_ = logger.Init()
+
+	healthy := &fakeSender{}
+	stuck := &fakeSender{block: make(chan struct{})}
+	s := New([]Destination{{Name: "old", Sender: stuck}, {Name: "new", Sender: healthy}}, 0)
+
+	start := time.Now()
+	require.NoError(t, s.ProcessWithKey("k1", report(1), nil))
+	require.NoError(t, s.ProcessWithKey("k2", report(2), nil))
+	require.Less(t, time.Since(start), time.Second)
+
+	require.Eventually(t, func() bool { return len(healthy.delivered()) == 2 }, time.Second, 5*time.Millisecond)
+	require.Equal(t, []string{"k1", "k2"}, healthy.keys)
+
+	close(stuck.block)
+	require.NoError(t, s.Close(context.Background()))
+	require.Equal(t, []int64{1, 2}, stuck.delivered())
+}
+
+func TestSender_RetriesWithOriginalKeys(t *testing.T) {
+	_ = logger.Init()
+
+	down := &fakeSender{err: errors.New("connection refused")}
+	s := New([]Destination{{Name: "grpc", Sender: down}}, 0)
+
+	require.NoError(t, s.ProcessWithKey("k1", report(1), nil))
+	require.Eventually(t, func() bool { return s.Pending("grpc") == 1 }, time.Second, 5*time.Millisecond)
+
+	down.setErr(nil)
+	require.NoError(t, s.ProcessWithKey("k2", report(2), nil))
+
+	require.Eventually(t, func() bool { return len(down.delivered()) == 2 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"k1", "k2"}, down.keys)
+	require.NoError(t, s.Close(context.Background()))
+}
+
+func TestSender_DropsRejectedAndMergesOverflow(t *testing.T) {
+	_ = logger.Init()
+
+	rejecting := &fakeSender{err: retry.Permanent(errors.New("400 bad request"))}
+	s := New([]Destination{{Name: "http", Sender: rejecting}}, 0)
+	require.NoError(t, s.ProcessWithKey("k1", report(1), nil))
+	require.Eventually(t, func() bool { return s.Pending("http") == 0 }, time.Second, 5*time.Millisecond)
+	require.NoError(t, s.Close(context.Background()))
+
+	down := &fakeSender{err: errors.New("connection refused")}
+	s = New([]Destination{{Name: "http", Sender: down}}, 2)
+	for i := int64(1); i <= 4; i++ {
+		require.NoError(t, s.Process(report(i), nil))
+	}
+	require.LessOrEqual(t, s.Pending("http"), 2)
+
+	down.setErr(nil)
+	require.NoError(t, s.Close(context.Background()))
+
+	var total int64
+	for _, v := range down.delivered() {
+		total += v
+	}
+	require.Equal(t, int64(10), total, "merged reports keep every increment")
+}
+
+func TestDestination_PushNeverMergesAttemptedReports(t *testing.T) {
+	_ = logger.Init()
+
+	batch := func(key string, v int64, attempted bool) spool.Batch {
+		return spool.Batch{Key: key, Counters: report(v), Attempted: attempted}
+	}
+
+	d := &destination{Destination: Destination{Name: "http"}}
+	d.push(batch("k1", 1, true), 3)
+	d.push(batch("k2", 2, true), 3)
+	d.push(batch("k3", 3, false), 3)
+	d.push(batch("k4", 4, false), 3)
+
+	var keys []string
+	for _, b := range d.pending {
+		keys = append(keys, b.Key)
+	}
+	require.Equal(t, []string{"k1", "k2", "k4"}, keys, "only the never sent reports are merged")
+	require.Equal(t, int64(7), d.pending[2].Counters[0].Value)
+
+	d.inflight = true
+	d.pending[2].Attempted = true
+	d.push(batch("k5", 5, false), 3)
+
+	keys = nil
+	for _, b := range d.pending {
+		keys = append(keys, b.Key)
+	}
+	require.Equal(t, []string{"k1", "k4", "k5"}, keys, "without a pair to merge the oldest report not in flight is dropped")
+}
@@ -11,7 +11,9 @@ import (
 	pb "github.com/JinFuuMugen/ya_go_metrics/internal/proto"
 	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 type auditObserver struct {
@@ -61,8 +63,6 @@ func TestService_UpdateMetrics_StoresMetricsAndPublishesAuditWithIP(t *testing.T
 		Metrics: []*pb.Metric{
 			{Id: "Alloc", Type: pb.Metric_GAUGE, Value: 123.5},
 			{Id: "PollCount", Type: pb.Metric_COUNTER, Delta: 7},
-			nil,
-			{Id: "", Type: pb.Metric_GAUGE, Value: 1},
 		},
 	}
 
@@ -114,7 +114,7 @@ func TestService_UpdateMetrics_NoAuditPublisher(t *testing.T) {
 	require.Equal(t, 1.25, g.GetValue().(float64))
 }
 
-func TestService_UpdateMetrics_IgnoresNilAndEmptyID(t *testing.T) {
+func TestService_UpdateMetrics_RejectsWholeBatchOnInvalidMetric(t *testing.T) {
 	_ = logger.Init()
 
 	st := storage.NewStorage()
@@ -126,19 +126,84 @@ func TestService_UpdateMetrics_IgnoresNilAndEmptyID(t *testing.T) {
 
 	req := &pb.UpdateMetricsRequest{
 		Metrics: []*pb.Metric{
+			{Id: "G1", Type: pb.Metric_GAUGE, Value: 1},
 			nil,
 			{Id: "", Type: pb.Metric_COUNTER, Delta: 10},
 		},
 	}
 
 	resp, err := svc.UpdateMetrics(context.Background(), req)
-	require.NoError(t, err)
-	require.NotNil(t, resp)
+	require.Nil(t, resp)
+	require.Error(t, err)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = st.GetGauge("G1")
+	require.Error(t, err, "valid metrics must not be applied when the batch is rejected")
+
+	require.Len(t, obs.events, 0)
+}
+
+func TestService_UpdateMetrics_UnknownType_Unimplemented(t *testing.T) {
+	_ = logger.Init()
+
+	st := storage.NewStorage()
+	svc := New(st, nil)
+
+	req := &pb.UpdateMetricsRequest{
+		Metrics: []*pb.Metric{
+			{Id: "G1", Type: pb.Metric_GAUGE, Value: 1},
+			{Id: "X", Type: pb.Metric_MType(42), Value: 1},
+		},
+	}
 
-	_, err = st.GetGauge("anything")
+	_, err := svc.UpdateMetrics(context.Background(), req)
 	require.Error(t, err)
-	_, err = st.GetCounter("anything")
+	require.Equal(t, codes.Unimplemented, status.Code(err))
+
+	_, err = st.GetGauge("G1")
 	require.Error(t, err)
+}
 
-	require.Len(t, obs.events, 0)
+func TestService_UpdateMetrics_PartialMode_ReturnsStatuses(t *testing.T) {
+	_ = logger.Init()
+
+	st := storage.NewStorage()
+	p := audit.NewPublisher()
+	obs := &auditObserver{}
+	p.Subscribe(obs)
+
+	svc := New(st, p)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-batch-mode", "partial"))
+
+	req := &pb.UpdateMetricsRequest{
+		Metrics: []*pb.Metric{
+			{Id: "G1", Type: pb.Metric_GAUGE, Value: 1.5},
+			nil,
+			{Id: "", Type: pb.Metric_COUNTER, Delta: 10},
+			{Id: "C1", Type: pb.Metric_COUNTER, Delta: 3},
+		},
+	}
+
+	resp, err := svc.UpdateMetrics(ctx, req)
+	require.NoError(t, err)
+	require.Len(t, resp.Statuses, 4)
+
+	require.True(t, resp.Statuses[0].Ok)
+	require.False(t, resp.Statuses[1].Ok)
+	require.NotEmpty(t, resp.Statuses[1].Error)
+	require.False(t, resp.Statuses[2].Ok)
+	require.True(t, resp.Statuses[3].Ok)
+	require.Equal(t, "C1", resp.Statuses[3].Id)
+
+	g, err := st.GetGauge("G1")
+	require.NoError(t, err)
+	require.Equal(t, 1.5, g.Value)
+
+	c, err := st.GetCounter("C1")
+	require.NoError(t, err)
+	require.Equal(t, int64(3), c.Value)
+
+	require.Len(t, obs.events, 1)
+	require.ElementsMatch(t, []string{"G1", "C1"}, obs.events[0].Metrics)
 }
@@ -2,13 +2,19 @@ package grpcmetrics
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"time"
 
 	"github.com/JinFuuMugen/ya_go_metrics/internal/audit"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/batch"
 	"github.com/JinFuuMugen/ya_go_metrics/internal/models"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/network"
 	pb "github.com/JinFuuMugen/ya_go_metrics/internal/proto"
 	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 type Service struct {
@@ -22,42 +28,79 @@ func New(st storage.Storage, aud *audit.Publisher) *Service {
 	return &Service{st: st, aud: aud}
 }
 
+// UpdateMetrics applies a batch of metrics.
+// The batch is rejected as a whole if any metric is invalid, unless the
+// x-batch-mode metadata is set to partial, in which case valid metrics are
+// applied and a status is returned for every item.
 func (s *Service) UpdateMetrics(ctx context.Context, req *pb.UpdateMetricsRequest) (*pb.UpdateMetricsResponse, error) {
 	if req == nil || len(req.Metrics) == 0 {
 		return &pb.UpdateMetricsResponse{}, nil
 	}
 
-	affected := make([]string, 0, len(req.Metrics))
-	for _, m := range req.Metrics {
-		if m == nil || m.Id == "" {
-			continue
-		}
+	partial := batch.IsPartial(incomingValue(ctx, strings.ToLower(batch.ModeHeader)))
 
-		switch m.Type {
-		case pb.Metric_COUNTER:
-			s.st.AddCounter(m.Id, m.Delta)
-		case pb.Metric_GAUGE:
-			s.st.SetGauge(m.Id, m.Value)
-		default:
-		}
-
-		affected = append(affected, m.Id)
+	metrics := make([]models.Metrics, 0, len(req.Metrics))
+	for _, m := range req.Metrics {
+		metrics = append(metrics, toModel(m))
 	}
 
-	if s.aud != nil && len(affected) > 0 {
-		ip := ""
-		if md, ok := metadata.FromIncomingContext(ctx); ok {
-			if vals := md.Get("x-real-ip"); len(vals) > 0 {
-				ip = vals[0]
-			}
+	results, applied, err := batch.Apply(s.st, metrics, partial)
+	if err != nil {
+		if errors.Is(err, batch.ErrUnsupportedType) {
+			return nil, status.Error(codes.Unimplemented, err.Error())
 		}
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
 
+	if s.aud != nil && len(applied) > 0 {
 		s.aud.Publish(models.AuditEvent{
 			TS:        time.Now().Unix(),
-			Metrics:   affected,
-			IPAddress: ip,
+			Metrics:   applied,
+			IPAddress: incomingValue(ctx, network.HeaderXRealIP),
 		})
 	}
 
-	return &pb.UpdateMetricsResponse{}, nil
+	resp := &pb.UpdateMetricsResponse{}
+	if partial {
+		resp.Statuses = make([]*pb.MetricStatus, 0, len(results))
+		for _, r := range results {
+			resp.Statuses = append(resp.Statuses, &pb.MetricStatus{
+				Id:    r.ID,
+				Ok:    r.Status == batch.StatusOK,
+				Error: r.Error,
+			})
+		}
+	}
+
+	return resp, nil
+}
+
+func toModel(m *pb.Metric) models.Metrics {
+	if m == nil {
+		return models.Metrics{}
+	}
+
+	metric := models.Metrics{ID: m.Id}
+	switch m.Type {
+	case pb.Metric_COUNTER:
+		metric.MType = storage.MetricTypeCounter
+		metric.SetDelta(m.Delta)
+	case pb.Metric_GAUGE:
+		metric.MType = storage.MetricTypeGauge
+		metric.SetValue(m.Value)
+	default:
+		metric.MType = m.Type.String()
+	}
+	return metric
+}
+
+func incomingValue(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if vals := md.Get(key); len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
 }
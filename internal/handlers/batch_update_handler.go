@@ -2,10 +2,12 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/JinFuuMugen/ya_go_metrics/internal/audit"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/batch"
 	"github.com/JinFuuMugen/ya_go_metrics/internal/logger"
 	"github.com/JinFuuMugen/ya_go_metrics/internal/models"
 	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
@@ -13,6 +15,10 @@ import (
 
 // UpdateBatchMetricsHandler returns an HTTP handler for batch metric updates.
 // The handler accepts a JSON array of metrics.
+//
+// By default the batch is validated as a whole and applied only if every metric is valid.
+// With the "X-Batch-Mode: partial" header valid metrics are applied and
+// a JSON array with a status for every item is returned.
 func UpdateBatchMetricsHandler(
 	st storage.Storage,
 	auditPublisher *audit.Publisher,
@@ -33,47 +39,45 @@ func UpdateBatchMetricsHandler(
 			return
 		}
 
-		metricNames := make([]string, 0, len(metrics))
+		partial := batch.IsPartial(r.Header.Get(batch.ModeHeader))
 
-		for _, metric := range metrics {
-			switch metric.MType {
-
-			case storage.MetricTypeCounter:
-				delta, err := metric.GetDelta()
-				if err != nil {
-					logger.Errorf("invalid counter metric: %s", err)
-					http.Error(w, "bad counter metric", http.StatusBadRequest)
-					return
-				}
-				st.AddCounter(metric.ID, delta)
-
-			case storage.MetricTypeGauge:
-				value, err := metric.GetValue()
-				if err != nil {
-					logger.Errorf("invalid gauge metric: %s", err)
-					http.Error(w, "bad gauge metric", http.StatusBadRequest)
-					return
-				}
-				st.SetGauge(metric.ID, value)
-
-			default:
-				logger.Errorf("unsupported metric type: %s", metric.MType)
-				http.Error(w, "unsupported metric type", http.StatusNotImplemented)
+		results, applied, err := batch.Apply(st, metrics, partial)
+		if err != nil {
+			logger.Errorf("invalid metrics batch: %s", err)
+			if errors.Is(err, batch.ErrUnsupportedType) {
+				http.Error(w, err.Error(), http.StatusNotImplemented)
 				return
 			}
-
-			metricNames = append(metricNames, metric.ID)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
 
-		if auditPublisher != nil {
+		if auditPublisher != nil && len(applied) > 0 {
 			auditPublisher.Publish(models.AuditEvent{
 				TS:        time.Now().Unix(),
-				Metrics:   metricNames,
+				Metrics:   applied,
 				IPAddress: extractIP(r),
 			})
 		}
 
+		if !partial {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		resp, err := json.Marshal(results)
+		if err != nil {
+			logger.Errorf("cannot serialize batch results: %s", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
+
+		if _, err := w.Write(resp); err != nil {
+			logger.Errorf("cannot write response: %s", err)
+		}
 	}
 }
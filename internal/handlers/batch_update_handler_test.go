@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/batch"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/logger"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateBatchMetricsHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		mode        string
+		wantedCode  int
+		wantGauge   bool
+		wantResults []batch.Result
+	}{
+		{
+			name:       "valid batch",
+			body:       `[{"id":"G","type":"gauge","value":1.5},{"id":"C","type":"counter","delta":2}]`,
+			wantedCode: http.StatusOK,
+			wantGauge:  true,
+		},
+		{
+			name:       "bad counter rejects whole batch",
+			body:       `[{"id":"G","type":"gauge","value":1.5},{"id":"C","type":"counter"}]`,
+			wantedCode: http.StatusBadRequest,
+		},
+		{
+			name:       "empty id rejects whole batch",
+			body:       `[{"id":"G","type":"gauge","value":1.5},{"id":"","type":"gauge","value":1}]`,
+			wantedCode: http.StatusBadRequest,
+		},
+		{
+			name:       "unsupported type rejects whole batch",
+			body:       `[{"id":"G","type":"gauge","value":1.5},{"id":"X","type":"histogram","value":1}]`,
+			wantedCode: http.StatusNotImplemented,
+		},
+		{
+			name:       "partial mode applies valid metrics",
+			body:       `[{"id":"G","type":"gauge","value":1.5},{"id":"C","type":"counter"}]`,
+			mode:       batch.ModePartial,
+			wantedCode: http.StatusOK,
+			wantGauge:  true,
+			wantResults: []batch.Result{
+				{ID: "G", MType: "gauge", Status: batch.StatusOK},
+				{ID: "C", MType: "counter", Status: batch.StatusError, Error: batch.ErrNoDelta.Error()},
+			},
+		},
+	}
+
+	logger.Init()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := storage.NewStorage()
+			h := UpdateBatchMetricsHandler(st, nil)
+
+			req := httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader(tt.body))
+			if tt.mode != "" {
+				req.Header.Set(batch.ModeHeader, tt.mode)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.wantedCode, rr.Code)
+
+			_, err := st.GetGauge("G")
+			assert.Equal(t, tt.wantGauge, err == nil)
+
+			if tt.wantResults != nil {
+				var results []batch.Result
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &results))
+				assert.Equal(t, tt.wantResults, results)
+			}
+		})
+	}
+}
@@ -16,7 +16,12 @@ func GetMetricPlainHandler(
 	return func(w http.ResponseWriter, r *http.Request) {
 
 		metricType := chi.URLParam(r, "metric_type")
-		metricName := chi.URLParam(r, "metric_name")
+		metricName, err := plainMetricName(r)
+		if err != nil {
+			logger.Errorf("cannot get metric name: %s", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
 		w.Header().Set("Content-Type", "text/plain")
 
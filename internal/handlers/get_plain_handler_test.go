@@ -51,12 +51,19 @@ func TestGetMetricPlainHandle(t *testing.T) {
 			method:     http.MethodGet,
 			url:        "/value/metr/someValue",
 		},
+		{
+			name:       "escaped labelled metric",
+			wantedCode: http.StatusBadRequest,
+			method:     http.MethodGet,
+			url:        "/value/gauge/DiskFree%7Bmountpoint=%22%2Fvar%2Flib%22%7D",
+		},
 	}
 	logger.Init()
 
 	st := storage.NewStorage()
 	st.SetGauge("someG", 123.123)
 	st.AddCounter("someC", 123)
+	st.SetGauge(`DiskFree{mountpoint="/var/lib"}`, 1)
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			r := chi.NewRouter()
@@ -1,12 +1,22 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
 )
 
+// errLabelledPlainMetric is returned for labelled metric IDs on the plain
+// routes. Label values may contain slashes, so such IDs cannot be addressed
+// by a path segment and are only served by the JSON API.
+var errLabelledPlainMetric = errors.New("labelled metrics are only supported by the JSON API")
+
 func extractIP(r *http.Request) string {
 	host, _, err := net.SplitHostPort(r.RemoteAddr)
 	if err != nil {
@@ -25,3 +35,17 @@ func readRequestBody(r *http.Request) ([]byte, error) {
 
 	return body, nil
 }
+
+// plainMetricName returns the metric name of a plain route. Escaped names are
+// unescaped and labelled IDs are rejected with errLabelledPlainMetric. A name
+// with a % that does not start a valid escape is taken as it is.
+func plainMetricName(r *http.Request) (string, error) {
+	name := chi.URLParam(r, "metric_name")
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		name = unescaped
+	}
+	if strings.ContainsAny(name, "{}") {
+		return "", errLabelledPlainMetric
+	}
+	return name, nil
+}
@@ -20,7 +20,12 @@ func UpdateMetricsPlainHandler(
 	return func(w http.ResponseWriter, r *http.Request) {
 
 		metricType := chi.URLParam(r, "metric_type")
-		metricName := chi.URLParam(r, "metric_name")
+		metricName, err := plainMetricName(r)
+		if err != nil {
+			logger.Errorf("cannot get metric name: %s", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		metricValue := chi.URLParam(r, "metric_value")
 
 		switch metricType {
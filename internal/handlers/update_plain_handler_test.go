@@ -55,6 +55,24 @@ func TestUpdateMetricsHandlePlain(t *testing.T) {
 			method:     http.MethodPost,
 			url:        "/update/counter/someValue/120.321",
 		},
+		{
+			name:       "labelled metric",
+			wantedCode: 400,
+			method:     http.MethodPost,
+			url:        `/update/gauge/DiskFree{mountpoint="x"}/1`,
+		},
+		{
+			name:       "escaped labelled metric",
+			wantedCode: 400,
+			method:     http.MethodPost,
+			url:        "/update/gauge/DiskFree%7Bmountpoint=%22%2Fvar%2Flib%22%7D/1",
+		},
+		{
+			name:       "percent sign in name",
+			wantedCode: 200,
+			method:     http.MethodPost,
+			url:        "/update/gauge/Load%25zz/1",
+		},
 	}
 	logger.Init()
 	st := storage.NewStorage()
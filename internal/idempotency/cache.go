@@ -0,0 +1,121 @@
+package idempotency
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// HeaderKey is the HTTP header carrying the idempotency key of a request.
+const HeaderKey = "Idempotency-Key"
+
+// HeaderReplayed is set on responses that were served from the cache.
+const HeaderReplayed = "Idempotent-Replayed"
+
+// MetadataKey is the gRPC metadata key carrying the idempotency key of a call.
+const MetadataKey = "idempotency-key"
+
+// MetadataReplayed is the gRPC header metadata set on replayed responses.
+const MetadataReplayed = "idempotent-replayed"
+
+// Cache remembers results of requests by idempotency key for a fixed window.
+type Cache struct {
+	mu        sync.Mutex
+	window    time.Duration
+	entries   map[string]*entry
+	nextSweep time.Time
+	now       func() time.Time
+}
+
+type entry struct {
+	done    chan struct{}
+	value   any
+	stored  bool
+	expires time.Time
+}
+
+// NewCache creates a Cache keeping results for the given window.
+// A non-positive window disables caching.
+func NewCache(window time.Duration) *Cache {
+	return &Cache{
+		window:  window,
+		entries: make(map[string]*entry),
+		now:     time.Now,
+	}
+}
+
+// NewKey generates a random idempotency key.
+func NewKey() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Do runs fn at most once per key within the window.
+// Calls with a key that is already being processed wait for the first call and
+// return its result with replayed set to true. fn reports whether its result
+// should be remembered; results that are not remembered let the next call with
+// the same key run again.
+func (c *Cache) Do(key string, fn func() (value any, store bool)) (value any, replayed bool) {
+	if c == nil || c.window <= 0 || key == "" {
+		v, _ := fn()
+		return v, false
+	}
+
+	for {
+		c.mu.Lock()
+		now := c.now()
+		c.sweep(now)
+
+		e, ok := c.entries[key]
+		if ok && e.stored && now.After(e.expires) {
+			delete(c.entries, key)
+			ok = false
+		}
+
+		if !ok {
+			e = &entry{done: make(chan struct{})}
+			c.entries[key] = e
+			c.mu.Unlock()
+			return c.run(key, e, fn), false
+		}
+		c.mu.Unlock()
+
+		<-e.done
+		if e.stored {
+			return e.value, true
+		}
+	}
+}
+
+func (c *Cache) run(key string, e *entry, fn func() (any, bool)) (value any) {
+	store := false
+	defer func() {
+		c.mu.Lock()
+		if store {
+			e.value = value
+			e.stored = true
+			e.expires = c.now().Add(c.window)
+		} else {
+			delete(c.entries, key)
+		}
+		c.mu.Unlock()
+		close(e.done)
+	}()
+
+	value, store = fn()
+	return value
+}
+
+func (c *Cache) sweep(now time.Time) {
+	if now.Before(c.nextSweep) {
+		return
+	}
+	for k, e := range c.entries {
+		if e.stored && now.After(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.nextSweep = now.Add(c.window)
+}
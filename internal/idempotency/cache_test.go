@@ -0,0 +1,106 @@
+package idempotency
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_Do_ReplaysWithinWindow(t *testing.T) {
+	c := NewCache(time.Minute)
+
+	var calls int32
+	fn := func() (any, bool) {
+		return atomic.AddInt32(&calls, 1), true
+	}
+
+	v, replayed := c.Do("k", fn)
+	require.False(t, replayed)
+	require.Equal(t, int32(1), v)
+
+	v, replayed = c.Do("k", fn)
+	require.True(t, replayed)
+	require.Equal(t, int32(1), v)
+
+	v, replayed = c.Do("other", fn)
+	require.False(t, replayed)
+	require.Equal(t, int32(2), v)
+}
+
+func TestCache_Do_ExpiresAfterWindow(t *testing.T) {
+	now := time.Now()
+	c := NewCache(time.Minute)
+	c.now = func() time.Time { return now }
+
+	var calls int32
+	fn := func() (any, bool) {
+		return atomic.AddInt32(&calls, 1), true
+	}
+
+	c.Do("k", fn)
+	now = now.Add(2 * time.Minute)
+
+	_, replayed := c.Do("k", fn)
+	require.False(t, replayed)
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestCache_Do_NotStoredResultRunsAgain(t *testing.T) {
+	c := NewCache(time.Minute)
+
+	var calls int32
+	fn := func() (any, bool) {
+		return atomic.AddInt32(&calls, 1), false
+	}
+
+	c.Do("k", fn)
+	_, replayed := c.Do("k", fn)
+	require.False(t, replayed)
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestCache_Do_ConcurrentCallsRunOnce(t *testing.T) {
+	c := NewCache(time.Minute)
+
+	var calls int32
+	release := make(chan struct{})
+	fn := func() (any, bool) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "done", true
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, _ := c.Do("k", fn)
+			assert.Equal(t, "done", v)
+		}()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestCache_Do_Disabled(t *testing.T) {
+	c := NewCache(0)
+
+	var calls int32
+	fn := func() (any, bool) {
+		return atomic.AddInt32(&calls, 1), true
+	}
+
+	c.Do("k", fn)
+	_, replayed := c.Do("k", fn)
+	require.False(t, replayed)
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
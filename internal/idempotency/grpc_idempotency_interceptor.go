@@ -0,0 +1,59 @@
+package idempotency
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type callResult struct {
+	resp any
+	err  error
+}
+
+// UnaryServerInterceptor returns the original result for calls carrying an already
+// seen idempotency-key metadata instead of invoking the handler again.
+// Only successful calls and calls rejected as invalid are remembered.
+func UnaryServerInterceptor(cache *Cache) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+
+		md, ok := metadata.FromIncomingContext(ctx)
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		vals := md.Get(MetadataKey)
+		if len(vals) == 0 || vals[0] == "" {
+			return handler(ctx, req)
+		}
+
+		v, replayed := cache.Do(info.FullMethod+"|"+vals[0], func() (any, bool) {
+			resp, err := handler(ctx, req)
+			return callResult{resp: resp, err: err}, isFinal(err)
+		})
+
+		if replayed {
+			_ = grpc.SetHeader(ctx, metadata.Pairs(MetadataReplayed, "true"))
+		}
+
+		res := v.(callResult)
+		return res.resp, res.err
+	}
+}
+
+func isFinal(err error) bool {
+	switch status.Code(err) {
+	case codes.OK, codes.InvalidArgument, codes.Unimplemented:
+		return true
+	default:
+		return false
+	}
+}
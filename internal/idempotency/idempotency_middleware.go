@@ -0,0 +1,80 @@
+package idempotency
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/logger"
+)
+
+type response struct {
+	status      int
+	contentType string
+	body        []byte
+}
+
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// Middleware returns an HTTP middleware that replays the original response for
+// requests carrying an already seen Idempotency-Key header instead of handling them again.
+// Server errors are not remembered, so a retry after a 5xx response is processed anew.
+func Middleware(cache *Cache) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			key := r.Header.Get(HeaderKey)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			v, replayed := cache.Do(r.URL.Path+"|"+key, func() (any, bool) {
+				rw := &recordingWriter{ResponseWriter: w}
+				next.ServeHTTP(rw, r)
+
+				resp := response{
+					status:      rw.status,
+					contentType: rw.Header().Get("Content-Type"),
+					body:        rw.body.Bytes(),
+				}
+				if resp.status == 0 {
+					resp.status = http.StatusOK
+				}
+				return resp, resp.status < http.StatusInternalServerError
+			})
+
+			if !replayed {
+				return
+			}
+
+			resp := v.(response)
+			if resp.contentType != "" {
+				w.Header().Set("Content-Type", resp.contentType)
+			}
+			w.Header().Set(HeaderReplayed, "true")
+			w.WriteHeader(resp.status)
+			if _, err := w.Write(resp.body); err != nil {
+				logger.Errorf("cannot write replayed response: %s", err)
+			}
+		})
+	}
+}
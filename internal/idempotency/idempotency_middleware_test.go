@@ -0,0 +1,124 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestMiddleware_ReplaysOriginalResponse(t *testing.T) {
+	calls := 0
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`[{"id":"a"}]`))
+	})
+
+	h := Middleware(NewCache(time.Minute))(next)
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+		req.Header.Set(HeaderKey, "batch-1")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.Equal(t, `[{"id":"a"}]`, rr.Body.String())
+		require.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+		require.Equal(t, i == 1, rr.Header().Get(HeaderReplayed) == "true")
+	}
+
+	require.Equal(t, 1, calls)
+}
+
+func TestMiddleware_WithoutKey_AlwaysHandles(t *testing.T) {
+	calls := 0
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	})
+
+	h := Middleware(NewCache(time.Minute))(next)
+
+	for i := 0; i < 2; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/updates/", nil))
+	}
+
+	require.Equal(t, 2, calls)
+}
+
+func TestMiddleware_ServerErrorIsNotRemembered(t *testing.T) {
+	calls := 0
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	h := Middleware(NewCache(time.Minute))(next)
+
+	wantCodes := []int{http.StatusInternalServerError, http.StatusOK, http.StatusOK}
+	for _, want := range wantCodes {
+		req := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+		req.Header.Set(HeaderKey, "batch-1")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		require.Equal(t, want, rr.Code)
+	}
+
+	require.Equal(t, 2, calls)
+}
+
+func TestUnaryServerInterceptor_ReplaysResult(t *testing.T) {
+	ic := UnaryServerInterceptor(NewCache(time.Minute))
+	info := &grpc.UnaryServerInfo{FullMethod: "/metrics.Metrics/UpdateMetrics"}
+
+	calls := 0
+	handler := func(ctx context.Context, req any) (any, error) {
+		calls++
+		return "ok", nil
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataKey, "batch-1"))
+
+	for i := 0; i < 2; i++ {
+		resp, err := ic(ctx, "req", info, handler)
+		require.NoError(t, err)
+		require.Equal(t, "ok", resp)
+	}
+	require.Equal(t, 1, calls)
+
+	_, err := ic(context.Background(), "req", info, handler)
+	require.NoError(t, err)
+	require.Equal(t, 2, calls)
+}
+
+func TestUnaryServerInterceptor_UnavailableIsNotRemembered(t *testing.T) {
+	ic := UnaryServerInterceptor(NewCache(time.Minute))
+	info := &grpc.UnaryServerInfo{FullMethod: "/metrics.Metrics/UpdateMetrics"}
+
+	calls := 0
+	handler := func(ctx context.Context, req any) (any, error) {
+		calls++
+		return nil, status.Error(codes.Unavailable, "down")
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataKey, "batch-1"))
+
+	for i := 0; i < 2; i++ {
+		_, err := ic(ctx, "req", info, handler)
+		require.Equal(t, codes.Unavailable, status.Code(err))
+	}
+	require.Equal(t, 2, calls)
+}
@@ -1,8 +1,10 @@
 package io
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/JinFuuMugen/ya_go_metrics/internal/config"
@@ -11,71 +13,116 @@ import (
 	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
 )
 
+var errNoDatabase = errors.New("no database handle available to save metrics")
+
+// Dumper saves metrics to file or database, either every store interval or
+// after every update request when the interval is zero.
+type Dumper struct {
+	cfg      *config.ServerConfig
+	db       *database.Database
+	interval atomic.Int64
+	reset    chan struct{}
+}
+
 // Run initializes metric persistence according to the server configuration.
-func Run(cfg *config.ServerConfig, db *database.Database) error {
+func Run(cfg *config.ServerConfig, db *database.Database) (*Dumper, error) {
 	if cfg.FileStoragePath != "" {
 
 		if cfg.Restore {
 			if cfg.DatabaseDSN == "" {
 				err := loadMetricsFile(cfg.FileStoragePath)
 				if err != nil {
-					return fmt.Errorf("cannot read metrics from file: %w", err)
+					return nil, fmt.Errorf("cannot read metrics from file: %w", err)
 				}
 			} else {
 				err := loadMetricsDB(db)
 				if err != nil {
-					return fmt.Errorf("cannot read metrics from database: %w", err)
+					return nil, fmt.Errorf("cannot read metrics from database: %w", err)
 				}
 			}
 		}
 	}
-	if cfg.StoreInterval > 0 {
-		go runDumper(cfg, db)
+
+	d := &Dumper{cfg: cfg, db: db, reset: make(chan struct{}, 1)}
+	d.interval.Store(int64(cfg.StoreInterval))
+	go d.run()
+
+	return d, nil
+}
+
+// StoreInterval returns the current store interval.
+func (d *Dumper) StoreInterval() time.Duration {
+	return time.Duration(d.interval.Load())
+}
+
+// SetStoreInterval replaces the store interval. The periodic save is
+// rescheduled immediately, and a zero interval switches to saving after every update.
+func (d *Dumper) SetStoreInterval(interval time.Duration) {
+	d.interval.Store(int64(interval))
+
+	select {
+	case d.reset <- struct{}{}:
+	default:
 	}
-	return nil
 }
 
-func runDumper(cfg *config.ServerConfig, db *database.Database) {
-	storeTicker := time.NewTicker(cfg.StoreInterval)
-	for range storeTicker.C {
-		if cfg.DatabaseDSN != "" {
-			if db == nil {
-				logger.Errorf("no database handle available to save metrics")
+func (d *Dumper) run() {
+	var storeTicker *time.Ticker
+	var tick <-chan time.Time
+
+	schedule := func() {
+		if storeTicker != nil {
+			storeTicker.Stop()
+			storeTicker, tick = nil, nil
+		}
+		if interval := d.StoreInterval(); interval > 0 {
+			storeTicker = time.NewTicker(interval)
+			tick = storeTicker.C
+		}
+	}
+	schedule()
+
+	for {
+		select {
+		case <-d.reset:
+			schedule()
+		case <-tick:
+			err := d.save()
+			if err == nil {
 				continue
 			}
-
-			err := saveMetricsDB(db, storage.GetCounters(), storage.GetGauges())
-			if err != nil {
+			if d.cfg.DatabaseDSN != "" {
 				logger.Errorf("cannot save metrics into db: %s", err)
-			}
-		} else {
-			err := saveMetricsFile(cfg.FileStoragePath, storage.GetCounters(), storage.GetGauges())
-			if err != nil {
+			} else {
 				logger.Fatalf("cannot save metrics into file: %s", err)
 			}
 		}
+	}
+}
 
+func (d *Dumper) save() error {
+	counters, gauges := storage.Snapshot()
+	if d.cfg.DatabaseDSN == "" {
+		return saveMetricsFile(d.cfg.FileStoragePath, counters, gauges)
+	}
+	if d.db == nil {
+		return errNoDatabase
 	}
+	return saveMetricsDB(d.db, counters, gauges)
 }
 
 // GetDumperMiddleware returns an HTTP middleware that triggers metric persistence after request handling.
-// When StoreInterval is set to zero or less, metrics are saved synchronously after each request.
+// When the store interval of d is zero or less, metrics are saved synchronously after each request.
 // Metrics are saved either to file or database depending on the server configuration.
-func GetDumperMiddleware(cfg *config.ServerConfig, db *database.Database) func(next http.Handler) http.Handler {
+func GetDumperMiddleware(d *Dumper) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			next.ServeHTTP(w, r)
-			if cfg.StoreInterval <= 0 {
-				if cfg.DatabaseDSN == "" {
-					if err := saveMetricsFile(cfg.FileStoragePath, storage.GetCounters(), storage.GetGauges()); err != nil {
+			if d.StoreInterval() <= 0 {
+				if err := d.save(); err != nil {
+					if d.cfg.DatabaseDSN == "" {
 						logger.Errorf("cannot write metrics into file: %s", err)
-					}
-				} else {
-					if db == nil {
-						logger.Errorf("no database handle available to save metrics")
-						return
-					}
-					if err := saveMetricsDB(db, storage.GetCounters(), storage.GetGauges()); err != nil {
+					} else {
 						logger.Errorf("cannot write metrics into db: %s", err)
 					}
 				}
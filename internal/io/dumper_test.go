@@ -0,0 +1,37 @@
+package io
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDumper_SetStoreInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	cfg := &config.ServerConfig{FileStoragePath: path, StoreInterval: time.Hour}
+
+	d, err := Run(cfg, nil)
+	require.NoError(t, err)
+
+	h := GetDumperMiddleware(d)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/update/", nil))
+	require.NoFileExists(t, path)
+
+	d.SetStoreInterval(10 * time.Millisecond)
+	require.Equal(t, 10*time.Millisecond, d.StoreInterval())
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}, time.Second, 5*time.Millisecond)
+
+	d.SetStoreInterval(0)
+	require.NoError(t, os.Remove(path))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/update/", nil))
+	require.FileExists(t, path)
+}
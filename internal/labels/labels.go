@@ -0,0 +1,76 @@
+package labels
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Format returns the metric ID for name with the given labels in Prometheus
+// notation, e.g. DiskFree{mountpoint="/"}. Labels are sorted by key and a
+// name without labels is returned unchanged.
+func Format(name string, labels map[string]string) string {
+	if len(labels) == 0 {
+		return name
+	}
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(labels[k]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// Parse splits a metric ID produced by Format into its name and labels.
+// IDs without labels yield a nil label map.
+func Parse(id string) (string, map[string]string, error) {
+	open := strings.IndexByte(id, '{')
+	if open < 0 {
+		return id, nil, nil
+	}
+	if !strings.HasSuffix(id, "}") {
+		return "", nil, fmt.Errorf("invalid metric id %q: unterminated labels", id)
+	}
+
+	name := id[:open]
+	rest := id[open+1 : len(id)-1]
+	labels := make(map[string]string)
+
+	for rest != "" {
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return "", nil, fmt.Errorf("invalid metric id %q: bad label", id)
+		}
+		key := strings.TrimSpace(rest[:eq])
+		rest = rest[eq+1:]
+
+		quoted, err := strconv.QuotedPrefix(rest)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid metric id %q: bad label value: %w", id, err)
+		}
+		value, err := strconv.Unquote(quoted)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid metric id %q: bad label value: %w", id, err)
+		}
+		labels[key] = value
+
+		rest = strings.TrimPrefix(strings.TrimSpace(rest[len(quoted):]), ",")
+	}
+
+	return name, labels, nil
+}
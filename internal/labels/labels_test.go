@@ -0,0 +1,38 @@
+package labels
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	require.Equal(t, "Alloc", Format("Alloc", nil))
+	require.Equal(t, `DiskFree{mountpoint="/"}`, Format("DiskFree", map[string]string{"mountpoint": "/"}))
+	require.Equal(t, `NetBytesSent{host="a",interface="eth0"}`,
+		Format("NetBytesSent", map[string]string{"interface": "eth0", "host": "a"}))
+	require.Equal(t, `X{path="a\"b"}`, Format("X", map[string]string{"path": `a"b`}))
+}
+
+func TestParse(t *testing.T) {
+	name, l, err := Parse("Alloc")
+	require.NoError(t, err)
+	require.Equal(t, "Alloc", name)
+	require.Nil(t, l)
+
+	name, l, err = Parse(`NetBytesSent{host="a",interface="eth0"}`)
+	require.NoError(t, err)
+	require.Equal(t, "NetBytesSent", name)
+	require.Equal(t, map[string]string{"host": "a", "interface": "eth0"}, l)
+
+	name, l, err = Parse(Format("X", map[string]string{"path": `a"b,c`}))
+	require.NoError(t, err)
+	require.Equal(t, "X", name)
+	require.Equal(t, map[string]string{"path": `a"b,c`}, l)
+
+	_, _, err = Parse(`X{a="b"`)
+	require.Error(t, err)
+
+	_, _, err = Parse(`X{a=b}`)
+	require.Error(t, err)
+}
@@ -0,0 +1,204 @@
+package monitors
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/labels"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
+)
+
+// Aggregate statistics available for gauges.
+const (
+	AggregateMin   = "min"
+	AggregateMax   = "max"
+	AggregateMean  = "mean"
+	AggregateLast  = "last"
+	AggregateCount = "count"
+)
+
+// DefaultAggregateStats are the statistics reported when none are configured.
+// The plain gauge already holds the last sample.
+var DefaultAggregateStats = []string{AggregateMin, AggregateMax, AggregateMean, AggregateCount}
+
+// gaugeWindow holds the samples of one gauge since the last report.
+type gaugeWindow struct {
+	min, max, sum, last float64
+	count               int
+}
+
+func (w *gaugeWindow) add(v float64) {
+	if w.count == 0 || v < w.min {
+		w.min = v
+	}
+	if w.count == 0 || v > w.max {
+		w.max = v
+	}
+	w.sum += v
+	w.last = v
+	w.count++
+}
+
+// merge folds the older window o into w, keeping w's last sample.
+func (w *gaugeWindow) merge(o *gaugeWindow) {
+	if w.count == 0 {
+		*w = *o
+		return
+	}
+	w.min = math.Min(w.min, o.min)
+	w.max = math.Max(w.max, o.max)
+	w.sum += o.sum
+	w.count += o.count
+}
+
+// Aggregator is a storage.Storage that also keeps the samples of selected
+// gauges between two reports, so short spikes are not lost when a gauge is
+// overwritten several times per report interval.
+//
+// A Reporter created with an Aggregator adds the statistics as derived gauges
+// named <gauge>_<stat>, e.g. CPUutilization1_max or DiskUsed_mean{mountpoint="/"}.
+type Aggregator struct {
+	storage.Storage
+
+	mu      sync.Mutex
+	gauges  filter
+	stats   []string
+	windows map[string]*gaugeWindow
+}
+
+// NewAggregator wraps st. Only gauges matching one of the patterns are
+// aggregated. stats selects the reported statistics, DefaultAggregateStats if empty.
+func NewAggregator(st storage.Storage, patterns []string, stats []string) (*Aggregator, error) {
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("no gauges to aggregate")
+	}
+
+	f, err := newFilter(patterns, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(stats) == 0 {
+		stats = DefaultAggregateStats
+	}
+	for _, s := range stats {
+		switch s {
+		case AggregateMin, AggregateMax, AggregateMean, AggregateLast, AggregateCount:
+		default:
+			return nil, fmt.Errorf("unknown aggregate %q", s)
+		}
+	}
+
+	return &Aggregator{
+		Storage: st,
+		gauges:  f,
+		stats:   slices.Clone(stats),
+		windows: make(map[string]*gaugeWindow),
+	}, nil
+}
+
+// SetGauge stores the gauge and records the sample if the gauge is aggregated.
+func (a *Aggregator) SetGauge(name string, value float64) {
+	a.Storage.SetGauge(name, value)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.record(name, value)
+}
+
+// Update stores the counters and gauges as a single change and records the
+// samples of the aggregated gauges.
+func (a *Aggregator) Update(counters []storage.Counter, gauges []storage.Gauge) {
+	a.Storage.Update(counters, gauges)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, g := range gauges {
+		a.record(g.Name, g.Value)
+	}
+}
+
+// record adds a sample to the window of the gauge if it is aggregated.
+// a.mu must be held.
+func (a *Aggregator) record(name string, value float64) {
+	if !a.gauges.match(name) {
+		return
+	}
+
+	w, ok := a.windows[name]
+	if !ok {
+		w = &gaugeWindow{}
+		a.windows[name] = w
+	}
+	w.add(value)
+}
+
+// take returns the derived gauges of the current window and starts a new one.
+// The returned function puts the taken samples back if the report failed.
+func (a *Aggregator) take() ([]storage.Gauge, func()) {
+	a.mu.Lock()
+	taken := a.windows
+	a.windows = make(map[string]*gaugeWindow, len(taken))
+	a.mu.Unlock()
+
+	names := make([]string, 0, len(taken))
+	for name := range taken {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	gauges := make([]storage.Gauge, 0, len(names)*len(a.stats))
+	for _, name := range names {
+		w := taken[name]
+		for _, stat := range a.stats {
+			gauges = append(gauges, storage.Gauge{
+				Name:  derivedName(name, stat),
+				Type:  storage.MetricTypeGauge,
+				Value: w.value(stat),
+			})
+		}
+	}
+
+	restore := func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+
+		for name, old := range taken {
+			if w, ok := a.windows[name]; ok {
+				w.merge(old)
+				continue
+			}
+			a.windows[name] = old
+		}
+	}
+
+	return gauges, restore
+}
+
+func (w *gaugeWindow) value(stat string) float64 {
+	switch stat {
+	case AggregateMin:
+		return w.min
+	case AggregateMax:
+		return w.max
+	case AggregateMean:
+		return w.sum / float64(w.count)
+	case AggregateLast:
+		return w.last
+	default:
+		return float64(w.count)
+	}
+}
+
+// derivedName appends the statistic to the metric name, keeping its labels.
+func derivedName(id, stat string) string {
+	name, l, err := labels.Parse(id)
+	if err != nil {
+		return id + "_" + stat
+	}
+	return labels.Format(name+"_"+stat, l)
+}
@@ -0,0 +1,123 @@
+package monitors
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/batch"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/models"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+type gaugeRecorder struct {
+	err    error
+	gauges []map[string]float64
+}
+
+func (r *gaugeRecorder) Process(_ []storage.Counter, gauges []storage.Gauge) error {
+	if r.err != nil {
+		return r.err
+	}
+	m := make(map[string]float64, len(gauges))
+	for _, g := range gauges {
+		m[g.Name] = g.Value
+	}
+	r.gauges = append(r.gauges, m)
+	return nil
+}
+
+func (r *gaugeRecorder) ProcessWithKey(_ string, counters []storage.Counter, gauges []storage.Gauge) error {
+	return r.Process(counters, gauges)
+}
+
+func (r *gaugeRecorder) Compress(b []byte) ([]byte, error) { return b, nil }
+
+func TestNewAggregator_Validates(t *testing.T) {
+	_, err := NewAggregator(storage.NewStorage(), nil, nil)
+	require.Error(t, err)
+
+	_, err = NewAggregator(storage.NewStorage(), []string{".*"}, []string{"median"})
+	require.Error(t, err)
+}
+
+func TestAggregator_ReportsWindowStatistics(t *testing.T) {
+	agg, err := NewAggregator(storage.NewStorage(), []string{`^CPU`, `^Disk`}, nil)
+	require.NoError(t, err)
+
+	p := &gaugeRecorder{}
+	r := NewReporter(agg, p)
+
+	for _, v := range []float64{10, 95, 20, 15} {
+		agg.SetGauge("CPUutilization1", v)
+	}
+	agg.SetGauge(`DiskUsed{mountpoint="/"}`, 7)
+	agg.SetGauge("TotalMemory", 1024)
+
+	require.NoError(t, r.Report())
+
+	got := p.gauges[0]
+	require.Equal(t, float64(15), got["CPUutilization1"])
+	require.Equal(t, float64(10), got["CPUutilization1_min"])
+	require.Equal(t, float64(95), got["CPUutilization1_max"])
+	require.Equal(t, float64(35), got["CPUutilization1_mean"])
+	require.Equal(t, float64(4), got["CPUutilization1_count"])
+	require.Equal(t, float64(7), got[`DiskUsed_max{mountpoint="/"}`])
+	require.NotContains(t, got, "TotalMemory_max")
+
+	agg.SetGauge("CPUutilization1", 30)
+	require.NoError(t, r.Report())
+
+	got = p.gauges[1]
+	require.Equal(t, float64(30), got["CPUutilization1_min"])
+	require.Equal(t, float64(1), got["CPUutilization1_count"])
+	require.NotContains(t, got, `DiskUsed_max{mountpoint="/"}`)
+}
+
+func TestAggregator_KeepsSamplesOnFailedReport(t *testing.T) {
+	agg, err := NewAggregator(storage.NewStorage(), []string{".*"}, []string{AggregateMax, AggregateCount, AggregateLast})
+	require.NoError(t, err)
+
+	p := &gaugeRecorder{err: errors.New("server unavailable")}
+	r := NewReporter(agg, p)
+
+	agg.SetGauge("Load", 9)
+	require.Error(t, r.Report())
+
+	agg.SetGauge("Load", 2)
+	p.err = nil
+	require.NoError(t, r.Report())
+
+	got := p.gauges[0]
+	require.Equal(t, float64(9), got["Load_max"])
+	require.Equal(t, float64(2), got["Load_count"])
+	require.Equal(t, float64(2), got["Load_last"])
+}
+
+func TestAggregator_RecordsBatchUpdates(t *testing.T) {
+	agg, err := NewAggregator(storage.NewStorage(), []string{`^Queue`}, []string{AggregateMax, AggregateCount})
+	require.NoError(t, err)
+
+	p := &gaugeRecorder{}
+	r := NewReporter(agg, p)
+
+	for _, v := range []float64{4, 12} {
+		depth := models.Metrics{ID: "QueueDepth", MType: storage.MetricTypeGauge}
+		depth.SetValue(v)
+		hits := models.Metrics{ID: "Hits", MType: storage.MetricTypeCounter}
+		hits.SetDelta(1)
+
+		_, _, err := batch.Apply(agg, []models.Metrics{depth, hits}, false)
+		require.NoError(t, err)
+	}
+
+	counters, _ := agg.Snapshot()
+	require.Equal(t, []storage.Counter{{Name: "Hits", Type: storage.MetricTypeCounter, Value: 2}}, counters)
+
+	require.NoError(t, r.Report())
+
+	got := p.gauges[0]
+	require.Equal(t, float64(12), got["QueueDepth"])
+	require.Equal(t, float64(12), got["QueueDepth_max"])
+	require.Equal(t, float64(2), got["QueueDepth_count"])
+}
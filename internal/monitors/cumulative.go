@@ -0,0 +1,62 @@
+package monitors
+
+import "math"
+
+// cumulative turns monotonically growing system totals into counter deltas.
+// The first observation of a key only records a baseline, so totals accumulated
+// before the agent started are not reported as a single huge increment.
+type cumulative struct {
+	prev map[string]uint64
+}
+
+func newCumulative() *cumulative {
+	return &cumulative{prev: make(map[string]uint64)}
+}
+
+// delta returns the increment of key since the previous observation.
+// A total lower than the previous one means the source was reset and the
+// whole new total is the increment.
+func (c *cumulative) delta(key string, total uint64) (int64, bool) {
+	prev, ok := c.prev[key]
+	c.prev[key] = total
+	if !ok {
+		return 0, false
+	}
+	if total < prev {
+		return int64(total), true
+	}
+	return int64(total - prev), true
+}
+
+// fractionalCumulative is cumulative for totals with a fractional part, such
+// as Prometheus counters of seconds. Only whole increments are returned and
+// the remainder is carried over to the next observation, so no part of the
+// total is lost to truncation.
+type fractionalCumulative struct {
+	prev map[string]float64
+	rest map[string]float64
+}
+
+func newFractionalCumulative() *fractionalCumulative {
+	return &fractionalCumulative{prev: make(map[string]float64), rest: make(map[string]float64)}
+}
+
+// delta returns the whole increment of key since the previous observation.
+// A total lower than the previous one means the source was reset.
+func (c *fractionalCumulative) delta(key string, total float64) (int64, bool) {
+	prev, ok := c.prev[key]
+	c.prev[key] = total
+	if !ok {
+		return 0, false
+	}
+
+	inc := total - prev
+	if total < prev {
+		inc = total
+	}
+
+	acc := c.rest[key] + inc
+	whole := math.Floor(acc)
+	c.rest[key] = acc - whole
+	return int64(whole), true
+}
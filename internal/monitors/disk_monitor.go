@@ -0,0 +1,142 @@
+package monitors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/labels"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
+	"github.com/shirou/gopsutil/disk"
+)
+
+func init() {
+	RegisterOptIn("disk", func(st storage.Storage, options json.RawMessage) (Monitor, error) {
+		var opts DiskOptions
+		if err := decodeOptions(options, &opts); err != nil {
+			return nil, err
+		}
+		return NewDiskMonitor(st, opts)
+	})
+}
+
+// DiskOptions configures the disk collector.
+type DiskOptions struct {
+	// IncludeMountpoints lists regular expressions of mountpoints to report. Empty means all.
+	IncludeMountpoints []string `json:"include_mountpoints"`
+
+	// ExcludeMountpoints lists regular expressions of mountpoints to skip.
+	ExcludeMountpoints []string `json:"exclude_mountpoints"`
+
+	// IncludeDevices lists regular expressions of block devices to report IO for. Empty means all.
+	IncludeDevices []string `json:"include_devices"`
+
+	// ExcludeDevices lists regular expressions of block devices to skip.
+	ExcludeDevices []string `json:"exclude_devices"`
+
+	// AllPartitions also reports pseudo filesystems such as proc or tmpfs.
+	AllPartitions bool `json:"all_partitions"`
+}
+
+type diskMonitor struct {
+	Storage storage.Storage
+
+	mountpoints filter
+	devices     filter
+	all         bool
+	io          *cumulative
+
+	partitions func(all bool) ([]disk.PartitionStat, error)
+	usage      func(path string) (*disk.UsageStat, error)
+	ioCounters func(names ...string) (map[string]disk.IOCountersStat, error)
+}
+
+// NewDiskMonitor creates a monitor reporting per-mountpoint disk usage as gauges
+// and per-device IO as counters.
+func NewDiskMonitor(s storage.Storage, opts DiskOptions) (Monitor, error) {
+	mountpoints, err := newFilter(opts.IncludeMountpoints, opts.ExcludeMountpoints)
+	if err != nil {
+		return nil, fmt.Errorf("mountpoint filter: %w", err)
+	}
+
+	devices, err := newFilter(opts.IncludeDevices, opts.ExcludeDevices)
+	if err != nil {
+		return nil, fmt.Errorf("device filter: %w", err)
+	}
+
+	return &diskMonitor{
+		Storage:     s,
+		mountpoints: mountpoints,
+		devices:     devices,
+		all:         opts.AllPartitions,
+		io:          newCumulative(),
+		partitions:  disk.Partitions,
+		usage:       disk.Usage,
+		ioCounters:  disk.IOCounters,
+	}, nil
+}
+
+// Collect collects disk usage and IO metrics.
+func (m *diskMonitor) Collect() error {
+	return errors.Join(m.collectUsage(), m.collectIO())
+}
+
+func (m *diskMonitor) collectUsage() error {
+	parts, err := m.partitions(m.all)
+	if err != nil {
+		return fmt.Errorf("cannot get partitions: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	var errs []error
+	for _, p := range parts {
+		if seen[p.Mountpoint] || !m.mountpoints.match(p.Mountpoint) {
+			continue
+		}
+		seen[p.Mountpoint] = true
+
+		u, err := m.usage(p.Mountpoint)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cannot get usage of %s: %w", p.Mountpoint, err))
+			continue
+		}
+
+		l := map[string]string{"mountpoint": p.Mountpoint}
+		m.Storage.SetGauge(labels.Format("DiskTotal", l), float64(u.Total))
+		m.Storage.SetGauge(labels.Format("DiskUsed", l), float64(u.Used))
+		m.Storage.SetGauge(labels.Format("DiskFree", l), float64(u.Free))
+		m.Storage.SetGauge(labels.Format("DiskUsedPercent", l), u.UsedPercent)
+		m.Storage.SetGauge(labels.Format("DiskInodesTotal", l), float64(u.InodesTotal))
+		m.Storage.SetGauge(labels.Format("DiskInodesUsed", l), float64(u.InodesUsed))
+		m.Storage.SetGauge(labels.Format("DiskInodesFree", l), float64(u.InodesFree))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (m *diskMonitor) collectIO() error {
+	counters, err := m.ioCounters()
+	if err != nil {
+		return fmt.Errorf("cannot get disk io counters: %w", err)
+	}
+
+	for name, c := range counters {
+		if !m.devices.match(name) {
+			continue
+		}
+
+		l := map[string]string{"device": name}
+		m.addTotal(labels.Format("DiskReadBytes", l), c.ReadBytes)
+		m.addTotal(labels.Format("DiskWriteBytes", l), c.WriteBytes)
+		m.addTotal(labels.Format("DiskReadOps", l), c.ReadCount)
+		m.addTotal(labels.Format("DiskWriteOps", l), c.WriteCount)
+	}
+
+	return nil
+}
+
+func (m *diskMonitor) addTotal(name string, total uint64) {
+	if d, ok := m.io.delta(name, total); ok {
+		m.Storage.AddCounter(name, d)
+	}
+}
@@ -0,0 +1,86 @@
+package monitors
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
+	"github.com/shirou/gopsutil/disk"
+	"github.com/stretchr/testify/require"
+)
+
+func newFakeDiskMonitor(t *testing.T, st storage.Storage, opts DiskOptions, io map[string]disk.IOCountersStat) *diskMonitor {
+	t.Helper()
+
+	m, err := NewDiskMonitor(st, opts)
+	require.NoError(t, err)
+
+	dm := m.(*diskMonitor)
+	dm.partitions = func(bool) ([]disk.PartitionStat, error) {
+		return []disk.PartitionStat{
+			{Device: "/dev/sda1", Mountpoint: "/"},
+			{Device: "/dev/sda2", Mountpoint: "/home"},
+			{Device: "/dev/sda2", Mountpoint: "/home"},
+			{Device: "/dev/loop0", Mountpoint: "/snap/core"},
+		}, nil
+	}
+	dm.usage = func(path string) (*disk.UsageStat, error) {
+		return &disk.UsageStat{Path: path, Total: 100, Used: 40, Free: 60, UsedPercent: 40, InodesTotal: 10, InodesUsed: 1, InodesFree: 9}, nil
+	}
+	dm.ioCounters = func(...string) (map[string]disk.IOCountersStat, error) {
+		return io, nil
+	}
+	return dm
+}
+
+func TestDiskMonitor_UsagePerMountpoint(t *testing.T) {
+	st := storage.NewStorage()
+	m := newFakeDiskMonitor(t, st, DiskOptions{ExcludeMountpoints: []string{`^/snap/`}}, nil)
+
+	require.NoError(t, m.Collect())
+
+	g, err := st.GetGauge(`DiskUsed{mountpoint="/home"}`)
+	require.NoError(t, err)
+	require.Equal(t, float64(40), g.Value)
+
+	_, err = st.GetGauge(`DiskInodesFree{mountpoint="/"}`)
+	require.NoError(t, err)
+
+	_, err = st.GetGauge(`DiskTotal{mountpoint="/snap/core"}`)
+	require.Error(t, err)
+}
+
+func TestDiskMonitor_IOCountersAreDeltas(t *testing.T) {
+	st := storage.NewStorage()
+	io := map[string]disk.IOCountersStat{
+		"sda":   {ReadBytes: 1000, WriteBytes: 500, ReadCount: 10, WriteCount: 5},
+		"loop0": {ReadBytes: 1},
+	}
+	m := newFakeDiskMonitor(t, st, DiskOptions{IncludeDevices: []string{`^sd`}}, io)
+
+	require.NoError(t, m.Collect())
+	_, err := st.GetCounter(`DiskReadBytes{device="sda"}`)
+	require.Error(t, err, "first observation must only set a baseline")
+
+	io["sda"] = disk.IOCountersStat{ReadBytes: 1600, WriteBytes: 500, ReadCount: 13, WriteCount: 5}
+	require.NoError(t, m.Collect())
+
+	c, err := st.GetCounter(`DiskReadBytes{device="sda"}`)
+	require.NoError(t, err)
+	require.Equal(t, int64(600), c.Value)
+
+	c, err = st.GetCounter(`DiskReadOps{device="sda"}`)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), c.Value)
+
+	_, err = st.GetCounter(`DiskReadBytes{device="loop0"}`)
+	require.Error(t, err)
+}
+
+func TestDiskMonitor_InvalidOptions(t *testing.T) {
+	_, err := NewDiskMonitor(storage.NewStorage(), DiskOptions{IncludeMountpoints: []string{"("}})
+	require.Error(t, err)
+
+	var opts DiskOptions
+	require.Error(t, decodeOptions(json.RawMessage(`{"unknown": true}`), &opts))
+}
@@ -0,0 +1,304 @@
+package monitors
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/batch"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/labels"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/models"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
+)
+
+func init() {
+	RegisterOptIn("exec", func(st storage.Storage, options json.RawMessage) (Monitor, error) {
+		var opts ExecOptions
+		if err := decodeOptions(options, &opts); err != nil {
+			return nil, err
+		}
+		return NewExecMonitor(st, opts)
+	})
+}
+
+// Output formats understood by the exec collector.
+const (
+	// ExecFormatText is one "name type value" metric per line; empty lines and
+	// lines starting with # are ignored.
+	ExecFormatText = "text"
+
+	// ExecFormatJSON is a models.Metrics object or an array of them.
+	ExecFormatJSON = "json"
+)
+
+const defaultExecTimeout = 10 * time.Second
+
+// ExecCommand describes one command run by the exec collector.
+type ExecCommand struct {
+	// Name is the value of the command label of the exec status metrics.
+	Name string `json:"name"`
+
+	// Command is the program and its arguments. It is not run through a shell.
+	Command []string `json:"command"`
+
+	// Format is ExecFormatText (the default) or ExecFormatJSON.
+	Format string `json:"format"`
+
+	// Timeout overrides ExecOptions.Timeout, e.g. "3s".
+	Timeout string `json:"timeout"`
+}
+
+// ExecOptions configures the exec collector.
+type ExecOptions struct {
+	// Commands lists the commands run on every poll.
+	Commands []ExecCommand `json:"commands"`
+
+	// Timeout limits the run time of each command, e.g. "10s". Defaults to 10 seconds.
+	Timeout string `json:"timeout"`
+}
+
+type execCommand struct {
+	ExecCommand
+	timeout time.Duration
+}
+
+type execMonitor struct {
+	Storage storage.Storage
+
+	commands []execCommand
+	run      func(ctx context.Context, argv []string) ([]byte, error)
+}
+
+// NewExecMonitor creates a monitor that runs the configured commands on every
+// poll and stores the metrics they print. For every command it also reports
+// ExecSuccess, ExecDuration (seconds) and an ExecFailures counter labeled with
+// the command name, so broken checks show up on the server.
+func NewExecMonitor(s storage.Storage, opts ExecOptions) (Monitor, error) {
+	timeout := defaultExecTimeout
+	if opts.Timeout != "" {
+		d, err := parsePositiveDuration(opts.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout: %w", err)
+		}
+		timeout = d
+	}
+
+	seen := make(map[string]bool)
+	commands := make([]execCommand, 0, len(opts.Commands))
+
+	for _, c := range opts.Commands {
+		if c.Name == "" {
+			return nil, errors.New("exec command without name")
+		}
+		if seen[c.Name] {
+			return nil, fmt.Errorf("duplicate exec command %q", c.Name)
+		}
+		seen[c.Name] = true
+
+		if len(c.Command) == 0 || c.Command[0] == "" {
+			return nil, fmt.Errorf("exec command %q: empty command", c.Name)
+		}
+
+		switch c.Format {
+		case "":
+			c.Format = ExecFormatText
+		case ExecFormatText, ExecFormatJSON:
+		default:
+			return nil, fmt.Errorf("exec command %q: unknown format %q", c.Name, c.Format)
+		}
+
+		ec := execCommand{ExecCommand: c, timeout: timeout}
+		if c.Timeout != "" {
+			d, err := parsePositiveDuration(c.Timeout)
+			if err != nil {
+				return nil, fmt.Errorf("exec command %q: invalid timeout: %w", c.Name, err)
+			}
+			ec.timeout = d
+		}
+		commands = append(commands, ec)
+	}
+
+	return &execMonitor{
+		Storage:  s,
+		commands: commands,
+		run:      runCommand,
+	}, nil
+}
+
+// Collect runs all commands concurrently and stores their output.
+func (m *execMonitor) Collect() error {
+	errs := make([]error, len(m.commands))
+
+	var wg sync.WaitGroup
+	for i, c := range m.commands {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = m.collect(c)
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+func (m *execMonitor) collect(c execCommand) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	out, err := m.run(ctx, c.Command)
+	if ctx.Err() != nil {
+		err = fmt.Errorf("timed out after %s", c.timeout)
+	}
+
+	if err == nil {
+		err = m.store(c.Format, out)
+	}
+
+	l := map[string]string{"command": c.Name}
+	m.Storage.SetGauge(labels.Format("ExecDuration", l), time.Since(start).Seconds())
+
+	if err != nil {
+		m.Storage.SetGauge(labels.Format("ExecSuccess", l), 0)
+		m.Storage.AddCounter(labels.Format("ExecFailures", l), 1)
+		return fmt.Errorf("exec command %q: %w", c.Name, err)
+	}
+
+	m.Storage.SetGauge(labels.Format("ExecSuccess", l), 1)
+	m.Storage.AddCounter(labels.Format("ExecFailures", l), 0)
+	return nil
+}
+
+// store parses out and applies every valid metric. Invalid entries are
+// reported as an error after the valid ones have been stored.
+func (m *execMonitor) store(format string, out []byte) error {
+	var metrics []models.Metrics
+	var err error
+
+	switch format {
+	case ExecFormatJSON:
+		metrics, err = parseExecJSON(out)
+	default:
+		metrics, err = parseExecText(out)
+	}
+
+	results, _, _ := batch.Apply(m.Storage, metrics, true)
+
+	errs := []error{err}
+	for _, r := range results {
+		if r.Status == batch.StatusError {
+			errs = append(errs, fmt.Errorf("metric %q: %s", r.ID, r.Error))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func parseExecText(out []byte) ([]models.Metrics, error) {
+	var metrics []models.Metrics
+	var errs []error
+
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 3 {
+			errs = append(errs, fmt.Errorf("line %d: expected \"name type value\"", line))
+			continue
+		}
+
+		m := models.Metrics{ID: fields[0], MType: fields[1]}
+		switch m.MType {
+		case storage.MetricTypeCounter:
+			d, err := strconv.ParseInt(fields[2], 10, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("line %d: invalid counter value %q", line, fields[2]))
+				continue
+			}
+			m.SetDelta(d)
+		case storage.MetricTypeGauge:
+			v, err := strconv.ParseFloat(fields[2], 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("line %d: invalid gauge value %q", line, fields[2]))
+				continue
+			}
+			m.SetValue(v)
+		}
+		metrics = append(metrics, m)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read output: %w", err)
+	}
+
+	return metrics, errors.Join(errs...)
+}
+
+func parseExecJSON(out []byte) ([]models.Metrics, error) {
+	out = bytes.TrimSpace(out)
+	if len(out) == 0 {
+		return nil, nil
+	}
+
+	var metrics []models.Metrics
+	if out[0] == '[' {
+		if err := json.Unmarshal(out, &metrics); err != nil {
+			return nil, fmt.Errorf("cannot parse output: %w", err)
+		}
+		return metrics, nil
+	}
+
+	var m models.Metrics
+	if err := json.Unmarshal(out, &m); err != nil {
+		return nil, fmt.Errorf("cannot parse output: %w", err)
+	}
+	return []models.Metrics{m}, nil
+}
+
+func runCommand(ctx context.Context, argv []string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.WaitDelay = time.Second
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, truncate(msg, 256))
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+func parsePositiveDuration(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("duration must be positive, got %s", s)
+	}
+	return d, nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
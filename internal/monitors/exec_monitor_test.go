@@ -0,0 +1,112 @@
+package monitors
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewExecMonitor_ValidatesCommands(t *testing.T) {
+	st := storage.NewStorage()
+
+	_, err := NewExecMonitor(st, ExecOptions{Commands: []ExecCommand{{Command: []string{"true"}}}})
+	require.Error(t, err)
+
+	_, err = NewExecMonitor(st, ExecOptions{Commands: []ExecCommand{{Name: "a"}}})
+	require.Error(t, err)
+
+	_, err = NewExecMonitor(st, ExecOptions{Commands: []ExecCommand{{Name: "a", Command: []string{"true"}, Format: "xml"}}})
+	require.Error(t, err)
+
+	_, err = NewExecMonitor(st, ExecOptions{Timeout: "-1s"})
+	require.Error(t, err)
+}
+
+func TestExecMonitor_TextAndJSON(t *testing.T) {
+	st := storage.NewStorage()
+	m, err := NewExecMonitor(st, ExecOptions{Commands: []ExecCommand{
+		{Name: "text", Command: []string{"text"}},
+		{Name: "json", Command: []string{"json"}, Format: ExecFormatJSON},
+	}})
+	require.NoError(t, err)
+
+	m.(*execMonitor).run = func(_ context.Context, argv []string) ([]byte, error) {
+		if argv[0] == "text" {
+			return []byte("# queue stats\nQueueLength gauge 12.5\n\nJobsDone counter 3\n"), nil
+		}
+		return []byte(`[{"id":"Temperature","type":"gauge","value":36.6}]`), nil
+	}
+
+	require.NoError(t, m.Collect())
+	require.NoError(t, m.Collect())
+
+	g, err := st.GetGauge("QueueLength")
+	require.NoError(t, err)
+	require.Equal(t, 12.5, g.Value)
+
+	c, err := st.GetCounter("JobsDone")
+	require.NoError(t, err)
+	require.Equal(t, int64(6), c.Value)
+
+	g, err = st.GetGauge("Temperature")
+	require.NoError(t, err)
+	require.Equal(t, 36.6, g.Value)
+
+	g, err = st.GetGauge(`ExecSuccess{command="json"}`)
+	require.NoError(t, err)
+	require.Equal(t, float64(1), g.Value)
+}
+
+func TestExecMonitor_FailuresAreReported(t *testing.T) {
+	st := storage.NewStorage()
+	m, err := NewExecMonitor(st, ExecOptions{Commands: []ExecCommand{
+		{Name: "broken", Command: []string{"broken"}},
+		{Name: "partial", Command: []string{"partial"}},
+	}})
+	require.NoError(t, err)
+
+	m.(*execMonitor).run = func(_ context.Context, argv []string) ([]byte, error) {
+		if argv[0] == "broken" {
+			return nil, errors.New("exit status 1")
+		}
+		return []byte("Good gauge 1\nBad gauge nope\nWeird histogram 1\n"), nil
+	}
+
+	require.Error(t, m.Collect())
+
+	c, err := st.GetCounter(`ExecFailures{command="broken"}`)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), c.Value)
+
+	g, err := st.GetGauge(`ExecSuccess{command="partial"}`)
+	require.NoError(t, err)
+	require.Equal(t, float64(0), g.Value)
+
+	_, err = st.GetGauge("Good")
+	require.NoError(t, err, "valid lines are stored even if others are broken")
+}
+
+func TestExecMonitor_RunsCommandsWithTimeout(t *testing.T) {
+	st := storage.NewStorage()
+	m, err := NewExecMonitor(st, ExecOptions{Commands: []ExecCommand{
+		{Name: "echo", Command: []string{"sh", "-c", "echo 'Answer gauge 42'"}},
+		{Name: "slow", Command: []string{"sleep", "5"}, Timeout: "50ms"},
+	}})
+	require.NoError(t, err)
+
+	start := time.Now()
+	require.ErrorContains(t, m.Collect(), "timed out")
+	require.Less(t, time.Since(start), 3*time.Second)
+
+	g, err := st.GetGauge("Answer")
+	require.NoError(t, err)
+	require.Equal(t, float64(42), g.Value)
+
+	g, err = st.GetGauge(`ExecSuccess{command="slow"}`)
+	require.NoError(t, err)
+	require.Equal(t, float64(0), g.Value)
+}
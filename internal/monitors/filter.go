@@ -0,0 +1,57 @@
+package monitors
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// filter matches names against include and exclude regular expressions.
+// A name passes if it matches any include pattern (or there are none)
+// and matches no exclude pattern.
+type filter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+func newFilter(include, exclude []string) (filter, error) {
+	var f filter
+	var err error
+
+	if f.include, err = compilePatterns(include); err != nil {
+		return filter{}, err
+	}
+	if f.exclude, err = compilePatterns(exclude); err != nil {
+		return filter{}, err
+	}
+	return f, nil
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+func (f filter) match(name string) bool {
+	for _, re := range f.exclude {
+		if re.MatchString(name) {
+			return false
+		}
+	}
+
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, re := range f.include {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,22 +1,44 @@
 package monitors
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"strconv"
+	"sync"
 
-	"github.com/JinFuuMugen/ya_go_metrics/internal/sender"
 	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
 	"github.com/shirou/gopsutil/cpu"
+	"github.com/shirou/gopsutil/load"
 	"github.com/shirou/gopsutil/mem"
 )
 
+func init() {
+	Register("gopsutil", func(st storage.Storage, _ json.RawMessage) (Monitor, error) {
+		return NewGopsutilMonitor(st), nil
+	})
+}
+
 type gopsutilMonitor struct {
-	Storage   storage.Storage
-	Processor sender.Sender
+	Storage storage.Storage
+
+	mu       sync.Mutex
+	lastCPU  []cpu.TimesStat
+	lastAll  *cpu.TimesStat
+	memory   func() (*mem.VirtualMemoryStat, error)
+	cpuTimes func(percpu bool) ([]cpu.TimesStat, error)
+	loadAvg  func() (*load.AvgStat, error)
 }
 
 // NewGopsutilMonitor creates a new gopsutil-based monitor.
-func NewGopsutilMonitor(s storage.Storage, p sender.Sender) GopsutilMonitor {
-	return &gopsutilMonitor{s, p}
+func NewGopsutilMonitor(s storage.Storage) GopsutilMonitor {
+	return &gopsutilMonitor{
+		Storage:  s,
+		memory:   mem.VirtualMemory,
+		cpuTimes: cpu.Times,
+		loadAvg:  load.Avg,
+	}
 }
 
 // Collect collects system metrics using gopsutil.
@@ -24,38 +46,94 @@ func (m *gopsutilMonitor) Collect() error {
 	return m.CollectGopsutil()
 }
 
-// CollectGopsutil collects CPU and memory metrics from the system.
+// CollectGopsutil collects memory, CPU and load average metrics from the system.
+//
+// CPU utilization is computed from the CPU times seen on the previous call,
+// so it never sleeps; the first call only records a baseline. Per-core
+// utilization is stored as CPUutilization1..N, and CPUUser, CPUSystem,
+// CPUIowait and CPUSteal hold the share of all cores' time in each mode.
 func (m *gopsutilMonitor) CollectGopsutil() error {
-
-	vm, err := mem.VirtualMemory()
+	vm, err := m.memory()
 	if err != nil {
 		return fmt.Errorf("cannot get memory info: %w", err)
 	}
 
-	cpuPercent, err := cpu.Percent(0, false)
+	m.Storage.SetGauge("TotalMemory", float64(vm.Total))
+	m.Storage.SetGauge("FreeMemory", float64(vm.Available))
+
+	return errors.Join(m.collectCPU(), m.collectLoad())
+}
+
+func (m *gopsutilMonitor) collectCPU() error {
+	perCPU, err := m.cpuTimes(true)
 	if err != nil {
 		return fmt.Errorf("cannot get CPU info: %w", err)
 	}
 
-	m.Storage.SetGauge("TotalMemory", float64(vm.Total))
-	m.Storage.SetGauge("FreeMemory", float64(vm.Available))
-	m.Storage.SetGauge("CPUutilization1", cpuPercent[0])
+	all, err := m.cpuTimes(false)
+	if err != nil {
+		return fmt.Errorf("cannot get CPU info: %w", err)
+	}
+	if len(all) == 0 {
+		return fmt.Errorf("cannot get CPU info: no CPU times reported")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.lastCPU) == len(perCPU) {
+		for i, t := range perCPU {
+			m.Storage.SetGauge("CPUutilization"+strconv.Itoa(i+1), busyPercent(m.lastCPU[i], t))
+		}
+	}
+
+	if m.lastAll != nil {
+		prev, cur := *m.lastAll, all[0]
+		if total := cpuTotal(cur) - cpuTotal(prev); total > 0 {
+			m.Storage.SetGauge("CPUUser", share(cur.User-prev.User, total))
+			m.Storage.SetGauge("CPUSystem", share(cur.System-prev.System, total))
+			m.Storage.SetGauge("CPUIowait", share(cur.Iowait-prev.Iowait, total))
+			m.Storage.SetGauge("CPUSteal", share(cur.Steal-prev.Steal, total))
+		}
+	}
+
+	m.lastCPU = perCPU
+	m.lastAll = &all[0]
 
 	return nil
 }
 
-// Dump sends collected metrics using the configured Sender.
-func (m *gopsutilMonitor) Dump() error {
-	c := m.Storage.GetCounters()
-	g := m.Storage.GetGauges()
-	err := m.Processor.Process(c, g)
+func (m *gopsutilMonitor) collectLoad() error {
+	avg, err := m.loadAvg()
 	if err != nil {
-		return fmt.Errorf("error dumping metric: %w", err)
+		return fmt.Errorf("cannot get load average: %w", err)
 	}
+
+	m.Storage.SetGauge("LoadAverage1", avg.Load1)
+	m.Storage.SetGauge("LoadAverage5", avg.Load5)
+	m.Storage.SetGauge("LoadAverage15", avg.Load15)
+
 	return nil
 }
 
-// SetProcessor updates the Sender used for dumping metrics.
-func (m *gopsutilMonitor) SetProcessor(p sender.Sender) {
-	m.Processor = p
+// busyPercent returns the share of CPU time spent working between two samples.
+// Time waiting for I/O is idle time and is reported separately as CPUIowait.
+func busyPercent(prev, cur cpu.TimesStat) float64 {
+	total := cpuTotal(cur) - cpuTotal(prev)
+	if total <= 0 {
+		return 0
+	}
+	idle := (cur.Idle - prev.Idle) + (cur.Iowait - prev.Iowait)
+	return share(total-idle, total)
+}
+
+// cpuTotal returns the total CPU time of t. Guest time is left out: Linux
+// already counts it in User and Nice, and some gopsutil versions add it again
+// in TimesStat.Total.
+func cpuTotal(t cpu.TimesStat) float64 {
+	return t.User + t.System + t.Nice + t.Iowait + t.Irq + t.Softirq + t.Steal + t.Idle
+}
+
+func share(part, total float64) float64 {
+	return math.Min(100, math.Max(0, part/total*100))
 }
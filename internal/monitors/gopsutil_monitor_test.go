@@ -0,0 +1,59 @@
+package monitors
+
+import (
+	"testing"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
+	"github.com/shirou/gopsutil/cpu"
+	"github.com/shirou/gopsutil/load"
+	"github.com/shirou/gopsutil/mem"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGopsutilMonitor_PerCoreAndBreakdown(t *testing.T) {
+	st := storage.NewStorage()
+	m := NewGopsutilMonitor(st).(*gopsutilMonitor)
+
+	times := map[bool][]cpu.TimesStat{
+		true:  {{User: 10, Idle: 90}, {User: 0, Idle: 100}},
+		false: {{User: 10, Idle: 190}},
+	}
+	m.memory = func() (*mem.VirtualMemoryStat, error) {
+		return &mem.VirtualMemoryStat{Total: 1024, Available: 512}, nil
+	}
+	m.cpuTimes = func(percpu bool) ([]cpu.TimesStat, error) { return times[percpu], nil }
+	m.loadAvg = func() (*load.AvgStat, error) { return &load.AvgStat{Load1: 1, Load5: 0.5, Load15: 0.25}, nil }
+
+	require.NoError(t, m.Collect())
+	_, err := st.GetGauge("CPUutilization1")
+	require.Error(t, err, "first collection must only record a baseline")
+
+	// Guest time is already part of User.
+	times[true] = []cpu.TimesStat{{User: 60, Guest: 20, Idle: 140}, {User: 0, Iowait: 25, Idle: 175}}
+	times[false] = []cpu.TimesStat{{User: 60, Guest: 20, Iowait: 25, Idle: 315}}
+	require.NoError(t, m.Collect())
+
+	g, err := st.GetGauge("CPUutilization1")
+	require.NoError(t, err)
+	require.InDelta(t, 50, g.Value, 1e-9)
+
+	g, err = st.GetGauge("CPUutilization2")
+	require.NoError(t, err)
+	require.InDelta(t, 0, g.Value, 1e-9, "waiting for I/O is not busy")
+
+	g, err = st.GetGauge("CPUUser")
+	require.NoError(t, err)
+	require.InDelta(t, 25, g.Value, 1e-9)
+
+	g, err = st.GetGauge("CPUIowait")
+	require.NoError(t, err)
+	require.InDelta(t, 12.5, g.Value, 1e-9)
+
+	g, err = st.GetGauge("LoadAverage15")
+	require.NoError(t, err)
+	require.Equal(t, 0.25, g.Value)
+
+	g, err = st.GetGauge("TotalMemory")
+	require.NoError(t, err)
+	require.Equal(t, float64(1024), g.Value)
+}
@@ -1,17 +1,11 @@
 package monitors
 
-import "github.com/JinFuuMugen/ya_go_metrics/internal/sender"
-
 // Monitor defines a common interface for metric collectors.
+// Monitors only gather metrics into storage; sending is done once per
+// report interval by the Reporter.
 type Monitor interface {
 	// Collect gathers metrics from the source and stores them internally.
 	Collect() error
-
-	// Dump sends collected metrics using the configured Sender.
-	Dump() error
-
-	// SetProcessor sets the Sender used to send collected metrics.
-	SetProcessor(p sender.Sender)
 }
 
 // RuntimeMonitor extends Monitor with runtime metric collection.
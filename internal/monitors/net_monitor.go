@@ -0,0 +1,128 @@
+package monitors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/labels"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
+	"github.com/shirou/gopsutil/net"
+)
+
+func init() {
+	RegisterOptIn("net", func(st storage.Storage, options json.RawMessage) (Monitor, error) {
+		var opts NetOptions
+		if err := decodeOptions(options, &opts); err != nil {
+			return nil, err
+		}
+		return NewNetMonitor(st, opts)
+	})
+}
+
+// tcpStates lists the TCP connection states reported by the net collector.
+// Every state is reported on each poll so gauges drop to zero when connections go away.
+var tcpStates = []string{
+	"ESTABLISHED", "SYN_SENT", "SYN_RECV", "FIN_WAIT1", "FIN_WAIT2", "TIME_WAIT",
+	"CLOSE", "CLOSE_WAIT", "LAST_ACK", "LISTEN", "CLOSING",
+}
+
+// NetOptions configures the network collector.
+type NetOptions struct {
+	// IncludeInterfaces lists regular expressions of interfaces to report. Empty means all.
+	IncludeInterfaces []string `json:"include_interfaces"`
+
+	// ExcludeInterfaces lists regular expressions of interfaces to skip.
+	ExcludeInterfaces []string `json:"exclude_interfaces"`
+
+	// DisableConnections turns off TCP connection state counting, which scans
+	// every process' file descriptors and can be expensive on busy hosts.
+	DisableConnections bool `json:"disable_connections"`
+}
+
+type netMonitor struct {
+	Storage storage.Storage
+
+	interfaces  filter
+	connections bool
+	io          *cumulative
+
+	ioCounters func(pernic bool) ([]net.IOCountersStat, error)
+	tcpConns   func(kind string) ([]net.ConnectionStat, error)
+}
+
+// NewNetMonitor creates a monitor reporting per-interface traffic as counters
+// and TCP connection states as gauges.
+func NewNetMonitor(s storage.Storage, opts NetOptions) (Monitor, error) {
+	interfaces, err := newFilter(opts.IncludeInterfaces, opts.ExcludeInterfaces)
+	if err != nil {
+		return nil, fmt.Errorf("interface filter: %w", err)
+	}
+
+	return &netMonitor{
+		Storage:     s,
+		interfaces:  interfaces,
+		connections: !opts.DisableConnections,
+		io:          newCumulative(),
+		ioCounters:  net.IOCounters,
+		tcpConns:    net.Connections,
+	}, nil
+}
+
+// Collect collects network interface and TCP connection metrics.
+func (m *netMonitor) Collect() error {
+	errs := []error{m.collectInterfaces()}
+	if m.connections {
+		errs = append(errs, m.collectConnections())
+	}
+	return errors.Join(errs...)
+}
+
+func (m *netMonitor) collectInterfaces() error {
+	counters, err := m.ioCounters(true)
+	if err != nil {
+		return fmt.Errorf("cannot get network io counters: %w", err)
+	}
+
+	for _, c := range counters {
+		if !m.interfaces.match(c.Name) {
+			continue
+		}
+
+		l := map[string]string{"interface": c.Name}
+		m.addTotal(labels.Format("NetBytesSent", l), c.BytesSent)
+		m.addTotal(labels.Format("NetBytesRecv", l), c.BytesRecv)
+		m.addTotal(labels.Format("NetPacketsSent", l), c.PacketsSent)
+		m.addTotal(labels.Format("NetPacketsRecv", l), c.PacketsRecv)
+		m.addTotal(labels.Format("NetErrIn", l), c.Errin)
+		m.addTotal(labels.Format("NetErrOut", l), c.Errout)
+		m.addTotal(labels.Format("NetDropIn", l), c.Dropin)
+		m.addTotal(labels.Format("NetDropOut", l), c.Dropout)
+	}
+
+	return nil
+}
+
+func (m *netMonitor) collectConnections() error {
+	conns, err := m.tcpConns("tcp")
+	if err != nil {
+		return fmt.Errorf("cannot get tcp connections: %w", err)
+	}
+
+	counts := make(map[string]int, len(tcpStates))
+	for _, c := range conns {
+		counts[c.Status]++
+	}
+
+	for _, state := range tcpStates {
+		m.Storage.SetGauge(labels.Format("TCPConnections", map[string]string{"state": state}), float64(counts[state]))
+	}
+
+	return nil
+}
+
+func (m *netMonitor) addTotal(name string, total uint64) {
+	if d, ok := m.io.delta(name, total); ok {
+		m.Storage.AddCounter(name, d)
+	}
+}
@@ -0,0 +1,63 @@
+package monitors
+
+import (
+	"testing"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
+	"github.com/shirou/gopsutil/net"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetMonitor_InterfaceCountersAreDeltas(t *testing.T) {
+	st := storage.NewStorage()
+	m, err := NewNetMonitor(st, NetOptions{ExcludeInterfaces: []string{`^lo$`}, DisableConnections: true})
+	require.NoError(t, err)
+
+	counters := []net.IOCountersStat{
+		{Name: "eth0", BytesSent: 100, BytesRecv: 200, Errin: 1},
+		{Name: "lo", BytesSent: 50},
+	}
+	nm := m.(*netMonitor)
+	nm.ioCounters = func(bool) ([]net.IOCountersStat, error) { return counters, nil }
+
+	require.NoError(t, m.Collect())
+
+	counters[0] = net.IOCountersStat{Name: "eth0", BytesSent: 150, BytesRecv: 260, Errin: 3}
+	counters[1] = net.IOCountersStat{Name: "lo", BytesSent: 70}
+	require.NoError(t, m.Collect())
+
+	c, err := st.GetCounter(`NetBytesSent{interface="eth0"}`)
+	require.NoError(t, err)
+	require.Equal(t, int64(50), c.Value)
+
+	c, err = st.GetCounter(`NetErrIn{interface="eth0"}`)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), c.Value)
+
+	_, err = st.GetCounter(`NetBytesSent{interface="lo"}`)
+	require.Error(t, err)
+}
+
+func TestNetMonitor_TCPStates(t *testing.T) {
+	st := storage.NewStorage()
+	m, err := NewNetMonitor(st, NetOptions{})
+	require.NoError(t, err)
+
+	nm := m.(*netMonitor)
+	nm.ioCounters = func(bool) ([]net.IOCountersStat, error) { return nil, nil }
+	nm.tcpConns = func(string) ([]net.ConnectionStat, error) {
+		return []net.ConnectionStat{
+			{Status: "ESTABLISHED"}, {Status: "ESTABLISHED"}, {Status: "LISTEN"},
+		}, nil
+	}
+
+	require.NoError(t, m.Collect())
+
+	g, err := st.GetGauge(`TCPConnections{state="ESTABLISHED"}`)
+	require.NoError(t, err)
+	require.Equal(t, float64(2), g.Value)
+
+	g, err = st.GetGauge(`TCPConnections{state="TIME_WAIT"}`)
+	require.NoError(t, err)
+	require.Equal(t, float64(0), g.Value)
+}
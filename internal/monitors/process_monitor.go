@@ -0,0 +1,311 @@
+package monitors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/labels"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
+	"github.com/shirou/gopsutil/process"
+)
+
+func init() {
+	RegisterOptIn("process", func(st storage.Storage, options json.RawMessage) (Monitor, error) {
+		var opts ProcessOptions
+		if err := decodeOptions(options, &opts); err != nil {
+			return nil, err
+		}
+		return NewProcessMonitor(st, opts)
+	})
+}
+
+// ProcessTarget selects the processes reported under one name.
+// Exactly one of Process, Pidfile and Cmdline must be set.
+type ProcessTarget struct {
+	// Name is the value of the process label.
+	Name string `json:"name"`
+
+	// Process matches processes by their exact executable name.
+	Process string `json:"process"`
+
+	// Pidfile points to a file holding the pid of the process.
+	Pidfile string `json:"pidfile"`
+
+	// Cmdline is a regular expression matched against the full command line.
+	Cmdline string `json:"cmdline"`
+}
+
+// ProcessOptions configures the process collector.
+type ProcessOptions struct {
+	// Processes lists the watched processes.
+	Processes []ProcessTarget `json:"processes"`
+}
+
+// procSample holds the values of one process read in a single poll.
+type procSample struct {
+	created int64
+	rss     uint64
+	cpu     float64
+	fds     int32
+	threads int32
+}
+
+// procCPU remembers the CPU time of a process seen on the previous poll.
+type procCPU struct {
+	created int64
+	cpu     float64
+	at      time.Time
+}
+
+type processTarget struct {
+	ProcessTarget
+	cmdline *regexp.Regexp
+}
+
+type processMonitor struct {
+	Storage storage.Storage
+
+	targets []processTarget
+	last    map[int32]procCPU
+
+	pids     func() ([]int32, error)
+	identify func(pid int32) (name, cmdline string, err error)
+	sample   func(pid int32) (procSample, error)
+	readFile func(name string) ([]byte, error)
+	now      func() time.Time
+}
+
+// NewProcessMonitor creates a monitor reporting RSS, CPU usage, open file
+// descriptors, threads and uptime of the configured processes.
+// Values of all processes matched by a target are summed; uptime is that of the oldest one.
+func NewProcessMonitor(s storage.Storage, opts ProcessOptions) (Monitor, error) {
+	seen := make(map[string]bool)
+	targets := make([]processTarget, 0, len(opts.Processes))
+
+	for _, t := range opts.Processes {
+		if t.Name == "" {
+			return nil, errors.New("process target without name")
+		}
+		if seen[t.Name] {
+			return nil, fmt.Errorf("duplicate process target %q", t.Name)
+		}
+		seen[t.Name] = true
+
+		selectors := 0
+		for _, v := range []string{t.Process, t.Pidfile, t.Cmdline} {
+			if v != "" {
+				selectors++
+			}
+		}
+		if selectors != 1 {
+			return nil, fmt.Errorf("process target %q must set exactly one of process, pidfile and cmdline", t.Name)
+		}
+
+		pt := processTarget{ProcessTarget: t}
+		if t.Cmdline != "" {
+			re, err := regexp.Compile(t.Cmdline)
+			if err != nil {
+				return nil, fmt.Errorf("process target %q: invalid cmdline pattern: %w", t.Name, err)
+			}
+			pt.cmdline = re
+		}
+		targets = append(targets, pt)
+	}
+
+	return &processMonitor{
+		Storage:  s,
+		targets:  targets,
+		last:     make(map[int32]procCPU),
+		pids:     process.Pids,
+		identify: identifyProcess,
+		sample:   sampleProcess,
+		readFile: os.ReadFile,
+		now:      time.Now,
+	}, nil
+}
+
+// Collect collects metrics of the configured processes.
+func (m *processMonitor) Collect() error {
+	if len(m.targets) == 0 {
+		return nil
+	}
+
+	matched, err := m.match()
+	if matched == nil {
+		return err
+	}
+
+	now := m.now()
+	last := make(map[int32]procCPU)
+	errs := []error{err}
+
+	for i, t := range m.targets {
+		var rss uint64
+		var cpu float64
+		var fds, threads int32
+		var oldest int64
+		count := 0
+
+		for _, pid := range matched[i] {
+			s, err := m.sample(pid)
+			if err != nil {
+				if t.Pidfile != "" {
+					errs = append(errs, fmt.Errorf("cannot read process %d of %q: %w", pid, t.Name, err))
+				}
+				continue
+			}
+
+			count++
+			rss += s.rss
+			fds += s.fds
+			threads += s.threads
+			if oldest == 0 || s.created < oldest {
+				oldest = s.created
+			}
+
+			if prev, ok := m.last[pid]; ok && prev.created == s.created {
+				if elapsed := now.Sub(prev.at).Seconds(); elapsed > 0 {
+					cpu += max(0, s.cpu-prev.cpu) / elapsed * 100
+				}
+			}
+			last[pid] = procCPU{created: s.created, cpu: s.cpu, at: now}
+		}
+
+		var uptime float64
+		if count > 0 {
+			uptime = now.Sub(time.UnixMilli(oldest)).Seconds()
+		}
+
+		l := map[string]string{"process": t.Name}
+		m.Storage.SetGauge(labels.Format("ProcessCount", l), float64(count))
+		m.Storage.SetGauge(labels.Format("ProcessRSS", l), float64(rss))
+		m.Storage.SetGauge(labels.Format("ProcessCPUPercent", l), cpu)
+		m.Storage.SetGauge(labels.Format("ProcessFDs", l), float64(fds))
+		m.Storage.SetGauge(labels.Format("ProcessThreads", l), float64(threads))
+		m.Storage.SetGauge(labels.Format("ProcessUptime", l), uptime)
+	}
+
+	m.last = last
+
+	return errors.Join(errs...)
+}
+
+// match returns the pids selected by each target, indexed like m.targets.
+// Unreadable pidfiles only leave their target empty; the returned slice is
+// nil when processes cannot be listed at all.
+func (m *processMonitor) match() ([][]int32, error) {
+	matched := make([][]int32, len(m.targets))
+	scan := false
+	var errs []error
+
+	for i, t := range m.targets {
+		if t.Pidfile == "" {
+			scan = true
+			continue
+		}
+
+		pid, err := m.readPidfile(t.Pidfile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("process target %q: %w", t.Name, err))
+			continue
+		}
+		matched[i] = []int32{pid}
+	}
+
+	if !scan {
+		return matched, errors.Join(errs...)
+	}
+
+	pids, err := m.pids()
+	if err != nil {
+		return nil, fmt.Errorf("cannot list processes: %w", err)
+	}
+
+	for _, pid := range pids {
+		name, cmdline, err := m.identify(pid)
+		if err != nil {
+			continue
+		}
+
+		for i, t := range m.targets {
+			switch {
+			case t.Process != "" && t.Process == name,
+				t.cmdline != nil && t.cmdline.MatchString(cmdline):
+				matched[i] = append(matched[i], pid)
+			}
+		}
+	}
+
+	return matched, errors.Join(errs...)
+}
+
+func (m *processMonitor) readPidfile(path string) (int32, error) {
+	data, err := m.readFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("cannot read pidfile: %w", err)
+	}
+
+	pid, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 32)
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("invalid pid in %s", path)
+	}
+	return int32(pid), nil
+}
+
+func identifyProcess(pid int32) (string, string, error) {
+	p, err := process.NewProcess(pid)
+	if err != nil {
+		return "", "", err
+	}
+
+	name, err := p.Name()
+	if err != nil {
+		return "", "", err
+	}
+
+	cmdline, err := p.Cmdline()
+	if err != nil {
+		return "", "", err
+	}
+	return name, cmdline, nil
+}
+
+func sampleProcess(pid int32) (procSample, error) {
+	p, err := process.NewProcess(pid)
+	if err != nil {
+		return procSample{}, err
+	}
+
+	var s procSample
+	if s.created, err = p.CreateTime(); err != nil {
+		return procSample{}, fmt.Errorf("cannot get create time: %w", err)
+	}
+
+	mi, err := p.MemoryInfo()
+	if err != nil {
+		return procSample{}, fmt.Errorf("cannot get memory info: %w", err)
+	}
+	s.rss = mi.RSS
+
+	times, err := p.Times()
+	if err != nil {
+		return procSample{}, fmt.Errorf("cannot get CPU times: %w", err)
+	}
+	s.cpu = times.User + times.System
+
+	if s.threads, err = p.NumThreads(); err != nil {
+		return procSample{}, fmt.Errorf("cannot get threads: %w", err)
+	}
+
+	// Reading the descriptors of processes owned by other users needs extra
+	// privileges, so a failure here is reported as zero instead of dropping the process.
+	s.fds, _ = p.NumFDs()
+
+	return s, nil
+}
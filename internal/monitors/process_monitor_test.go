@@ -0,0 +1,109 @@
+package monitors
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProcessMonitor_ValidatesTargets(t *testing.T) {
+	st := storage.NewStorage()
+
+	_, err := NewProcessMonitor(st, ProcessOptions{Processes: []ProcessTarget{{Process: "nginx"}}})
+	require.Error(t, err)
+
+	_, err = NewProcessMonitor(st, ProcessOptions{Processes: []ProcessTarget{{Name: "a", Process: "nginx", Pidfile: "/run/nginx.pid"}}})
+	require.Error(t, err)
+
+	_, err = NewProcessMonitor(st, ProcessOptions{Processes: []ProcessTarget{{Name: "a", Cmdline: "("}}})
+	require.Error(t, err)
+
+	_, err = NewProcessMonitor(st, ProcessOptions{Processes: []ProcessTarget{{Name: "a", Process: "x"}, {Name: "a", Process: "y"}}})
+	require.Error(t, err)
+}
+
+func TestProcessMonitor_Collect(t *testing.T) {
+	st := storage.NewStorage()
+	m, err := NewProcessMonitor(st, ProcessOptions{Processes: []ProcessTarget{
+		{Name: "web", Process: "nginx"},
+		{Name: "app", Cmdline: `java .*app\.jar`},
+		{Name: "db", Pidfile: "/run/db.pid"},
+	}})
+	require.NoError(t, err)
+
+	start := time.Unix(1000, 0)
+	now := start
+	cpu := map[int32]float64{10: 1, 11: 2, 20: 5, 30: 0}
+
+	pm := m.(*processMonitor)
+	pm.now = func() time.Time { return now }
+	pm.pids = func() ([]int32, error) { return []int32{1, 10, 11, 20, 30}, nil }
+	pm.identify = func(pid int32) (string, string, error) {
+		switch pid {
+		case 10, 11:
+			return "nginx", "nginx: worker", nil
+		case 20:
+			return "java", "java -jar /opt/app.jar", nil
+		case 1:
+			return "", "", errors.New("gone")
+		}
+		return "postgres", "postgres", nil
+	}
+	pm.sample = func(pid int32) (procSample, error) {
+		return procSample{
+			created: start.Add(-time.Duration(pid) * time.Second).UnixMilli(),
+			rss:     uint64(pid) * 100,
+			cpu:     cpu[pid],
+			fds:     3,
+			threads: 2,
+		}, nil
+	}
+	pm.readFile = func(string) ([]byte, error) { return []byte("30\n"), nil }
+
+	require.NoError(t, m.Collect())
+
+	g, err := st.GetGauge(`ProcessCount{process="web"}`)
+	require.NoError(t, err)
+	require.Equal(t, float64(2), g.Value)
+
+	g, err = st.GetGauge(`ProcessRSS{process="web"}`)
+	require.NoError(t, err)
+	require.Equal(t, float64(2100), g.Value)
+
+	g, err = st.GetGauge(`ProcessUptime{process="web"}`)
+	require.NoError(t, err)
+	require.Equal(t, float64(11), g.Value)
+
+	now = now.Add(10 * time.Second)
+	cpu[10], cpu[11], cpu[20] = 3, 4, 10
+	require.NoError(t, m.Collect())
+
+	g, err = st.GetGauge(`ProcessCPUPercent{process="web"}`)
+	require.NoError(t, err)
+	require.InDelta(t, 40, g.Value, 1e-9)
+
+	g, err = st.GetGauge(`ProcessCPUPercent{process="app"}`)
+	require.NoError(t, err)
+	require.InDelta(t, 50, g.Value, 1e-9)
+
+	g, err = st.GetGauge(`ProcessThreads{process="db"}`)
+	require.NoError(t, err)
+	require.Equal(t, float64(2), g.Value)
+}
+
+func TestProcessMonitor_MissingPidfile(t *testing.T) {
+	st := storage.NewStorage()
+	m, err := NewProcessMonitor(st, ProcessOptions{Processes: []ProcessTarget{{Name: "db", Pidfile: "/run/db.pid"}}})
+	require.NoError(t, err)
+
+	m.(*processMonitor).readFile = func(string) ([]byte, error) { return nil, os.ErrNotExist }
+	require.ErrorIs(t, m.Collect(), os.ErrNotExist)
+
+	g, err := st.GetGauge(`ProcessCount{process="db"}`)
+	require.NoError(t, err)
+	require.Equal(t, float64(0), g.Value)
+}
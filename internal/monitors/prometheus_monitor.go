@@ -0,0 +1,199 @@
+package monitors
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/labels"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/promtext"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
+)
+
+func init() {
+	RegisterOptIn("prometheus", func(st storage.Storage, options json.RawMessage) (Monitor, error) {
+		var opts PrometheusOptions
+		if err := decodeOptions(options, &opts); err != nil {
+			return nil, err
+		}
+		return NewPrometheusMonitor(st, opts)
+	})
+}
+
+const (
+	defaultScrapeTimeout = 5 * time.Second
+	maxScrapeBytes       = 16 << 20
+)
+
+// PrometheusTarget is an endpoint exposing metrics in the Prometheus text format.
+type PrometheusTarget struct {
+	// Job is the value of the job label added to every scraped metric.
+	Job string `json:"job"`
+
+	// URL is the address of the metrics endpoint, e.g. http://localhost:9100/metrics.
+	URL string `json:"url"`
+}
+
+// PrometheusOptions configures the Prometheus scrape collector.
+type PrometheusOptions struct {
+	// Targets lists the scraped endpoints.
+	Targets []PrometheusTarget `json:"targets"`
+
+	// Timeout limits a single scrape, e.g. "5s". Defaults to 5 seconds.
+	Timeout string `json:"timeout"`
+}
+
+type prometheusMonitor struct {
+	Storage storage.Storage
+
+	targets []PrometheusTarget
+	client  *http.Client
+	totals  *fractionalCumulative
+	mu      sync.Mutex
+}
+
+// NewPrometheusMonitor creates a monitor scraping the configured targets.
+//
+// Every sample gets a job label; a job label already present is kept as
+// exported_job. Counters and the _count, _sum and _bucket samples of
+// histograms and summaries are stored as counter increments since the
+// previous scrape, everything else as gauges.
+// Fractional increments are carried over until they add up to a whole one.
+// ScrapeSuccess and ScrapeDuration gauges report the state of each target.
+func NewPrometheusMonitor(s storage.Storage, opts PrometheusOptions) (Monitor, error) {
+	timeout := defaultScrapeTimeout
+	if opts.Timeout != "" {
+		d, err := parsePositiveDuration(opts.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout: %w", err)
+		}
+		timeout = d
+	}
+
+	seen := make(map[string]bool)
+	for _, t := range opts.Targets {
+		if t.Job == "" || t.URL == "" {
+			return nil, errors.New("prometheus target must have job and url")
+		}
+		if seen[t.Job] {
+			return nil, fmt.Errorf("duplicate prometheus job %q", t.Job)
+		}
+		seen[t.Job] = true
+	}
+
+	return &prometheusMonitor{
+		Storage: s,
+		targets: opts.Targets,
+		client:  &http.Client{Timeout: timeout},
+		totals:  newFractionalCumulative(),
+	}, nil
+}
+
+// Collect scrapes all targets concurrently.
+func (m *prometheusMonitor) Collect() error {
+	errs := make([]error, len(m.targets))
+
+	var wg sync.WaitGroup
+	for i, t := range m.targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = m.collect(t)
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+func (m *prometheusMonitor) collect(t PrometheusTarget) error {
+	start := time.Now()
+	samples, err := m.scrape(t.URL)
+
+	l := map[string]string{"job": t.Job}
+	m.Storage.SetGauge(labels.Format("ScrapeDuration", l), time.Since(start).Seconds())
+
+	if err != nil {
+		m.Storage.SetGauge(labels.Format("ScrapeSuccess", l), 0)
+		return fmt.Errorf("cannot scrape %s: %w", t.Job, err)
+	}
+	m.Storage.SetGauge(labels.Format("ScrapeSuccess", l), 1)
+
+	for _, s := range samples {
+		m.store(t.Job, s)
+	}
+	return nil
+}
+
+func (m *prometheusMonitor) scrape(url string) ([]promtext.Sample, error) {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/plain;version=0.0.4")
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return promtext.Parse(io.LimitReader(resp.Body, maxScrapeBytes))
+}
+
+// isCumulativeSample reports whether s is a running total: a counter or the
+// _count, _sum or _bucket sample of a histogram or summary.
+func isCumulativeSample(s promtext.Sample) bool {
+	switch s.Type {
+	case promtext.TypeCounter:
+		return true
+	case promtext.TypeHistogram, promtext.TypeSummary:
+		return strings.HasSuffix(s.Name, "_count") || strings.HasSuffix(s.Name, "_sum") || strings.HasSuffix(s.Name, "_bucket")
+	default:
+		return false
+	}
+}
+
+// store saves a sample of job. Running totals are stored as counter increments
+// and everything else as gauges. The _count, _sum and _bucket samples of a
+// histogram or summary are all increments from the same baseline, so the sum
+// and count held by the server cover the same scrapes; quantiles stay gauges.
+func (m *prometheusMonitor) store(job string, s promtext.Sample) {
+	if math.IsNaN(s.Value) || math.IsInf(s.Value, 0) || strings.HasSuffix(s.Name, "_created") {
+		return
+	}
+
+	l := make(map[string]string, len(s.Labels)+1)
+	for k, v := range s.Labels {
+		l[k] = v
+	}
+	if v, ok := l["job"]; ok {
+		l["exported_job"] = v
+	}
+	l["job"] = job
+
+	id := labels.Format(s.Name, l)
+
+	if !isCumulativeSample(s) || s.Value < 0 {
+		m.Storage.SetGauge(id, s.Value)
+		return
+	}
+
+	m.mu.Lock()
+	d, ok := m.totals.delta(id, s.Value)
+	m.mu.Unlock()
+	if ok {
+		m.Storage.AddCounter(id, d)
+	}
+}
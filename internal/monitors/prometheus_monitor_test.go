@@ -0,0 +1,105 @@
+package monitors
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrometheusMonitor_Scrape(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests++
+		fmt.Fprintf(w, `# TYPE http_requests_total counter
+http_requests_total{code="200",job="api"} %d
+# TYPE queue_depth gauge
+queue_depth 4
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="+Inf"} %d
+latency_seconds_count %d
+latency_seconds_sum %g
+temperature NaN
+`, 100*requests, 10*requests, 10*requests, 2.5*float64(requests))
+	}))
+	defer srv.Close()
+
+	st := storage.NewStorage()
+	m, err := NewPrometheusMonitor(st, PrometheusOptions{Targets: []PrometheusTarget{{Job: "app", URL: srv.URL}}})
+	require.NoError(t, err)
+
+	require.NoError(t, m.Collect())
+	require.NoError(t, m.Collect())
+
+	c, err := st.GetCounter(`http_requests_total{code="200",exported_job="api",job="app"}`)
+	require.NoError(t, err)
+	require.Equal(t, int64(100), c.Value)
+
+	c, err = st.GetCounter(`latency_seconds_count{job="app"}`)
+	require.NoError(t, err)
+	require.Equal(t, int64(10), c.Value)
+
+	g, err := st.GetGauge(`queue_depth{job="app"}`)
+	require.NoError(t, err)
+	require.Equal(t, float64(4), g.Value)
+
+	c, err = st.GetCounter(`latency_seconds_bucket{job="app",le="+Inf"}`)
+	require.NoError(t, err)
+	require.Equal(t, int64(10), c.Value)
+
+	c, err = st.GetCounter(`latency_seconds_sum{job="app"}`)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), c.Value, "the fractional part is carried over")
+
+	_, err = st.GetGauge(`temperature{job="app"}`)
+	require.Error(t, err)
+
+	g, err = st.GetGauge(`ScrapeSuccess{job="app"}`)
+	require.NoError(t, err)
+	require.Equal(t, float64(1), g.Value)
+}
+
+func TestPrometheusMonitor_FailedScrape(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	st := storage.NewStorage()
+	m, err := NewPrometheusMonitor(st, PrometheusOptions{Targets: []PrometheusTarget{{Job: "app", URL: srv.URL}}})
+	require.NoError(t, err)
+
+	require.Error(t, m.Collect())
+
+	g, err := st.GetGauge(`ScrapeSuccess{job="app"}`)
+	require.NoError(t, err)
+	require.Equal(t, float64(0), g.Value)
+
+	_, err = NewPrometheusMonitor(st, PrometheusOptions{Targets: []PrometheusTarget{{Job: "app"}}})
+	require.Error(t, err)
+}
+
+func TestPrometheusMonitor_CarriesFractionalIncrements(t *testing.T) {
+	totals := []string{"0.5", "0.9", "1.7", "2.6"}
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintf(w, "# TYPE cpu_seconds_total counter\ncpu_seconds_total %s\n", totals[requests])
+		requests++
+	}))
+	defer srv.Close()
+
+	st := storage.NewStorage()
+	m, err := NewPrometheusMonitor(st, PrometheusOptions{Targets: []PrometheusTarget{{Job: "app", URL: srv.URL}}})
+	require.NoError(t, err)
+
+	for range totals {
+		require.NoError(t, m.Collect())
+	}
+
+	c, err := st.GetCounter(`cpu_seconds_total{job="app"}`)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), c.Value, "2.1 seconds since the baseline, the remaining 0.1 is carried over")
+}
@@ -0,0 +1,188 @@
+package monitors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/config"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/logger"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
+)
+
+// Factory creates a Monitor writing into st.
+// options holds the collector-specific options from the agent config and may be empty.
+type Factory func(st storage.Storage, options json.RawMessage) (Monitor, error)
+
+type registration struct {
+	factory   Factory
+	byDefault bool
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]registration)
+)
+
+// Register makes a collector available under the given name. It runs
+// unless turned off in the agent config.
+// It panics if the name is already taken.
+func Register(name string, f Factory) {
+	register(name, registration{factory: f, byDefault: true})
+}
+
+// RegisterOptIn makes a collector available under the given name. It only
+// runs when enabled in the agent config.
+// It panics if the name is already taken.
+func RegisterOptIn(name string, f Factory) {
+	register(name, registration{factory: f})
+}
+
+func register(name string, r registration) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("monitors: collector %q registered twice", name))
+	}
+	registry[name] = r
+}
+
+// Registered returns the names of all registered collectors in sorted order.
+func Registered() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	return registeredNames()
+}
+
+func registeredNames() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Collector is a named Monitor polled on its own interval.
+type Collector struct {
+	// Name is the name the collector is registered under.
+	Name string
+
+	// Interval is the time between two Collect calls.
+	Interval time.Duration
+
+	// Monitor gathers the metrics.
+	Monitor Monitor
+
+	options json.RawMessage
+}
+
+// Build creates every registered collector enabled in cfg. Collectors
+// registered with RegisterOptIn have to be enabled explicitly.
+// Collectors configured in cfg but never registered are reported as an error.
+func Build(st storage.Storage, cfg *config.AgentConfig) ([]Collector, error) {
+	return Rebuild(st, cfg, nil)
+}
+
+// Rebuild is like Build but keeps the monitors of prev whose options did not
+// change, so collectors tracking totals do not start over from a new baseline.
+// prev must have been built for the same storage and no longer be running.
+func Rebuild(st storage.Storage, cfg *config.AgentConfig, prev []Collector) ([]Collector, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	for name := range cfg.Collectors {
+		if _, ok := registry[name]; !ok {
+			return nil, fmt.Errorf("unknown collector %q", name)
+		}
+	}
+	for _, name := range cfg.DisabledCollectors {
+		if _, ok := registry[name]; !ok {
+			logger.Warnf("disabled collector %q is not registered", name)
+		}
+	}
+
+	var collectors []Collector
+	for _, name := range registeredNames() {
+		r := registry[name]
+		if !cfg.CollectorEnabled(name, r.byDefault) {
+			logger.Infof("collector %s disabled", name)
+			continue
+		}
+
+		interval := cfg.CollectorInterval(name)
+		if interval <= 0 {
+			return nil, fmt.Errorf("collector %s: poll interval must be positive", name)
+		}
+
+		options := cfg.Collectors[name].Options
+		if i := slices.IndexFunc(prev, func(c Collector) bool {
+			return c.Name == name && bytes.Equal(c.options, options)
+		}); i >= 0 {
+			collectors = append(collectors, Collector{Name: name, Interval: interval, Monitor: prev[i].Monitor, options: options})
+			continue
+		}
+
+		m, err := r.factory(st, options)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create collector %s: %w", name, err)
+		}
+
+		collectors = append(collectors, Collector{Name: name, Interval: interval, Monitor: m, options: options})
+	}
+
+	return collectors, nil
+}
+
+// Run polls every collector on its own interval until ctx is done.
+// Collection errors are logged and do not stop the collector.
+// The returned function waits for all collectors to stop.
+func Run(ctx context.Context, collectors []Collector) (wait func()) {
+	var wg sync.WaitGroup
+
+	for _, c := range collectors {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			logger.Infof("collector %s started, poll interval %s", c.Name, c.Interval)
+
+			ticker := time.NewTicker(c.Interval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					if err := c.Monitor.Collect(); err != nil {
+						logger.Errorf("collector %s: %s", c.Name, err)
+					}
+				}
+			}
+		}()
+	}
+
+	return wg.Wait
+}
+
+// decodeOptions decodes collector options into v, rejecting unknown fields.
+// Empty options leave v untouched.
+func decodeOptions(options json.RawMessage, v any) error {
+	if len(options) == 0 || string(options) == "null" {
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(options))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid options: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package monitors
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/config"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/logger"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func collectorNames(collectors []Collector) []string {
+	var names []string
+	for _, c := range collectors {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func TestBuild_BuiltinCollectorsEnabledByDefault(t *testing.T) {
+	_ = logger.Init()
+
+	cfg := &config.AgentConfig{PollInterval: 2 * time.Second}
+
+	collectors, err := Build(storage.NewStorage(), cfg)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"runtime", "gopsutil"}, collectorNames(collectors),
+		"other collectors are opt-in")
+
+	for _, c := range collectors {
+		require.Equal(t, 2*time.Second, c.Interval)
+	}
+}
+
+func TestBuild_OptInCollectorsRunWhenEnabled(t *testing.T) {
+	_ = logger.Init()
+
+	enabled := true
+	cfg := &config.AgentConfig{
+		PollInterval:       2 * time.Second,
+		DisabledCollectors: []string{"gopsutil"},
+		Collectors: map[string]config.CollectorConfig{
+			"net": {Enabled: &enabled},
+		},
+	}
+
+	collectors, err := Build(storage.NewStorage(), cfg)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"runtime", "net"}, collectorNames(collectors))
+}
+
+func TestBuild_RespectsSwitchesAndIntervals(t *testing.T) {
+	_ = logger.Init()
+
+	disabled := false
+	cfg := &config.AgentConfig{
+		PollInterval: 2 * time.Second,
+		Collectors: map[string]config.CollectorConfig{
+			"gopsutil": {Enabled: &disabled},
+			"runtime":  {PollInterval: 500 * time.Millisecond},
+		},
+	}
+
+	collectors, err := Build(storage.NewStorage(), cfg)
+	require.NoError(t, err)
+	require.NotContains(t, collectorNames(collectors), "gopsutil")
+
+	for _, c := range collectors {
+		if c.Name == "runtime" {
+			require.Equal(t, 500*time.Millisecond, c.Interval)
+		}
+	}
+
+	cfg = &config.AgentConfig{PollInterval: 2 * time.Second, DisabledCollectors: []string{"runtime"}}
+	collectors, err = Build(storage.NewStorage(), cfg)
+	require.NoError(t, err)
+	require.NotContains(t, collectorNames(collectors), "runtime")
+}
+
+func TestBuild_UnknownCollector(t *testing.T) {
+	cfg := &config.AgentConfig{
+		PollInterval: 2 * time.Second,
+		Collectors:   map[string]config.CollectorConfig{"nope": {}},
+	}
+
+	_, err := Build(storage.NewStorage(), cfg)
+	require.Error(t, err)
+}
+
+type countingMonitor struct {
+	calls atomic.Int32
+}
+
+func (m *countingMonitor) Collect() error {
+	m.calls.Add(1)
+	return nil
+}
+
+func TestRegister_UsedByBuildAndRun(t *testing.T) {
+	_ = logger.Init()
+
+	m := &countingMonitor{}
+	Register("test-counting", func(storage.Storage, json.RawMessage) (Monitor, error) {
+		return m, nil
+	})
+	require.Contains(t, Registered(), "test-counting")
+	require.Panics(t, func() {
+		Register("test-counting", func(storage.Storage, json.RawMessage) (Monitor, error) { return m, nil })
+	})
+
+	var others []string
+	for _, name := range Registered() {
+		if name != "test-counting" {
+			others = append(others, name)
+		}
+	}
+
+	cfg := &config.AgentConfig{
+		PollInterval:       2 * time.Second,
+		DisabledCollectors: others,
+		Collectors: map[string]config.CollectorConfig{
+			"test-counting": {PollInterval: 5 * time.Millisecond},
+		},
+	}
+
+	collectors, err := Build(storage.NewStorage(), cfg)
+	require.NoError(t, err)
+	require.Equal(t, []string{"test-counting"}, collectorNames(collectors))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wait := Run(ctx, collectors)
+
+	require.Eventually(t, func() bool { return m.calls.Load() >= 2 }, time.Second, 5*time.Millisecond)
+
+	cancel()
+	wait()
+}
+
+func TestRebuild_KeepsUnchangedCollectors(t *testing.T) {
+	_ = logger.Init()
+
+	st := storage.NewStorage()
+	cfg := &config.AgentConfig{
+		PollInterval: 2 * time.Second,
+		Collectors: map[string]config.CollectorConfig{
+			"gopsutil": {Options: json.RawMessage(`{}`)},
+		},
+	}
+	prev, err := Build(st, cfg)
+	require.NoError(t, err)
+
+	monitor := func(collectors []Collector, name string) Monitor {
+		for _, c := range collectors {
+			if c.Name == name {
+				return c.Monitor
+			}
+		}
+		return nil
+	}
+
+	next := &config.AgentConfig{
+		PollInterval: time.Second,
+		Collectors: map[string]config.CollectorConfig{
+			"gopsutil": {Options: json.RawMessage(`{"changed":true}`)},
+		},
+	}
+	collectors, err := Rebuild(st, next, prev)
+	require.NoError(t, err)
+
+	require.Same(t, monitor(prev, "runtime"), monitor(collectors, "runtime"), "unchanged collectors keep their state")
+	require.NotSame(t, monitor(prev, "gopsutil"), monitor(collectors, "gopsutil"), "changed collectors start over")
+	for _, c := range collectors {
+		require.Equal(t, time.Second, c.Interval)
+	}
+}
@@ -0,0 +1,68 @@
+package monitors
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/sender"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
+)
+
+// Reporter is the single reporting stage of the agent.
+// Collectors gather into a shared storage and the Reporter sends one
+// snapshot of that storage per report.
+type Reporter struct {
+	mu         sync.Mutex
+	storage    storage.Storage
+	aggregator *Aggregator
+	sender     sender.Sender
+}
+
+// NewReporter creates a Reporter sending the contents of st with p.
+// Collectors are expected to collect into st. If st is an *Aggregator,
+// every report also carries the gauge statistics since the previous report.
+func NewReporter(st storage.Storage, p sender.Sender) *Reporter {
+	agg, _ := st.(*Aggregator)
+	return &Reporter{storage: st, aggregator: agg, sender: p}
+}
+
+// SetSender replaces the sender used by the following reports and returns
+// the previous one. It waits for a report in progress, so once it returns
+// the previous sender is no longer used and may be closed.
+func (r *Reporter) SetSender(p sender.Sender) sender.Sender {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prev := r.sender
+	r.sender = p
+	return prev
+}
+
+// Report sends a snapshot of all collected metrics and settles counter deltas.
+// Counters in storage hold increments not yet delivered to the server: after a
+// successful send the sent values are subtracted, so increments collected while
+// sending are kept. On failure nothing is subtracted and the deltas are carried
+// over into the next report. Concurrent calls are serialized so a delta is never settled twice.
+func (r *Reporter) Report() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	counters, gauges := r.storage.Snapshot()
+
+	restore := func() {}
+	if r.aggregator != nil {
+		var derived []storage.Gauge
+		derived, restore = r.aggregator.take()
+		gauges = append(gauges, derived...)
+	}
+
+	if err := r.sender.Process(counters, gauges); err != nil {
+		restore()
+		return fmt.Errorf("error dumping metric: %w", err)
+	}
+
+	for _, c := range counters {
+		r.storage.AddCounter(c.Name, -c.Value)
+	}
+	return nil
+}
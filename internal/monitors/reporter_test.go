@@ -0,0 +1,179 @@
+package monitors
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/config"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/logger"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingSender struct {
+	err   error
+	calls int
+	sent  []int64
+	names [][]string
+}
+
+func (r *recordingSender) Process(counters []storage.Counter, gauges []storage.Gauge) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.calls++
+
+	var names []string
+	for _, c := range counters {
+		names = append(names, c.Name)
+		if c.Name == "PollCount" {
+			r.sent = append(r.sent, c.Value)
+		}
+	}
+	for _, g := range gauges {
+		names = append(names, g.Name)
+	}
+	r.names = append(r.names, names)
+	return nil
+}
+
+func (r *recordingSender) ProcessWithKey(_ string, counters []storage.Counter, gauges []storage.Gauge) error {
+	return r.Process(counters, gauges)
+}
+
+func (r *recordingSender) Compress(b []byte) ([]byte, error) { return b, nil }
+
+// buildCollectors creates the named registered collectors writing into st.
+func buildCollectors(t *testing.T, st storage.Storage, names ...string) []Collector {
+	t.Helper()
+
+	var disabled []string
+	for _, name := range Registered() {
+		if !slices.Contains(names, name) {
+			disabled = append(disabled, name)
+		}
+	}
+
+	collectors, err := Build(st, &config.AgentConfig{PollInterval: time.Second, DisabledCollectors: disabled})
+	require.NoError(t, err)
+	require.Len(t, collectors, len(names))
+	return collectors
+}
+
+func collectAll(t *testing.T, collectors []Collector) {
+	t.Helper()
+	for _, c := range collectors {
+		require.NoError(t, c.Monitor.Collect())
+	}
+}
+
+func TestReporter_SendsOneSnapshotOfAllCollectors(t *testing.T) {
+	_ = logger.Init()
+
+	st := storage.NewStorage()
+	p := &recordingSender{}
+	r := NewReporter(st, p)
+	collectors := buildCollectors(t, st, "runtime", "gopsutil")
+
+	collectAll(t, collectors)
+	require.NoError(t, r.Report())
+
+	require.Equal(t, 1, p.calls)
+	require.Contains(t, p.names[0], "Alloc")
+	require.Contains(t, p.names[0], "TotalMemory")
+	require.Contains(t, p.names[0], "PollCount")
+}
+
+func TestReporter_SendsDeltasSinceLastSuccess(t *testing.T) {
+	_ = logger.Init()
+
+	st := storage.NewStorage()
+	p := &recordingSender{}
+	r := NewReporter(st, p)
+	collectors := buildCollectors(t, st, "runtime")
+
+	for i := 0; i < 5; i++ {
+		collectAll(t, collectors)
+	}
+	require.NoError(t, r.Report())
+
+	for i := 0; i < 3; i++ {
+		collectAll(t, collectors)
+	}
+	require.NoError(t, r.Report())
+
+	require.Equal(t, []int64{5, 3}, p.sent)
+}
+
+func TestReporter_CarriesDeltasOverOnFailure(t *testing.T) {
+	_ = logger.Init()
+
+	st := storage.NewStorage()
+	p := &recordingSender{err: errors.New("server unavailable")}
+	r := NewReporter(st, p)
+	collectors := buildCollectors(t, st, "runtime")
+
+	collectAll(t, collectors)
+	collectAll(t, collectors)
+	require.Error(t, r.Report())
+
+	collectAll(t, collectors)
+	require.Error(t, r.Report())
+
+	p.err = nil
+	collectAll(t, collectors)
+	require.NoError(t, r.Report())
+	require.Equal(t, []int64{4}, p.sent)
+
+	require.NoError(t, r.Report())
+	require.Equal(t, []int64{4, 0}, p.sent, "nothing collected since the last successful send")
+}
+
+func TestReporter_SetSenderKeepsUndeliveredDeltas(t *testing.T) {
+	_ = logger.Init()
+
+	st := storage.NewStorage()
+	old := &recordingSender{err: errors.New("server unavailable")}
+	r := NewReporter(st, old)
+	collectors := buildCollectors(t, st, "runtime")
+
+	collectAll(t, collectors)
+	require.Error(t, r.Report())
+
+	p := &recordingSender{}
+	require.Same(t, old, r.SetSender(p))
+
+	collectAll(t, collectors)
+	require.NoError(t, r.Report())
+	require.Equal(t, []int64{2}, p.sent)
+	require.Zero(t, old.calls)
+}
+
+// snapshotOnlyStorage fails the test if a report reads counters and gauges
+// separately, as a collector may write in between.
+type snapshotOnlyStorage struct {
+	storage.Storage
+	t *testing.T
+}
+
+func (s snapshotOnlyStorage) GetCounters() []storage.Counter {
+	s.t.Error("report must read storage with Snapshot")
+	return s.Storage.GetCounters()
+}
+
+func (s snapshotOnlyStorage) GetGauges() []storage.Gauge {
+	s.t.Error("report must read storage with Snapshot")
+	return s.Storage.GetGauges()
+}
+
+func TestReporter_ReadsOneSnapshot(t *testing.T) {
+	st := snapshotOnlyStorage{Storage: storage.NewStorage(), t: t}
+	st.AddCounter("PollCount", 2)
+	st.SetGauge("Alloc", 1)
+
+	p := &recordingSender{}
+	require.NoError(t, NewReporter(st, p).Report())
+	require.Equal(t, [][]string{{"PollCount", "Alloc"}}, p.names)
+}
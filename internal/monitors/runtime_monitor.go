@@ -1,22 +1,59 @@
 package monitors
 
 import (
-	"fmt"
+	"encoding/json"
+	"math"
 	"math/rand"
 	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"sync"
 
-	"github.com/JinFuuMugen/ya_go_metrics/internal/sender"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/labels"
 	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
 )
 
+func init() {
+	Register("runtime", func(st storage.Storage, _ json.RawMessage) (Monitor, error) {
+		return NewRuntimeMonitor(st), nil
+	})
+}
+
+// histogramQuantiles are the quantiles reported for runtime histograms.
+var histogramQuantiles = []float64{0.5, 0.9, 0.99, 1}
+
 type runtimeMonitor struct {
-	Storage   storage.Storage
-	Processor sender.Sender
+	Storage storage.Storage
+
+	mu      sync.Mutex
+	descs   []metrics.Description
+	samples []metrics.Sample
+	index   map[string]int
+	totals  *cumulative
 }
 
 // NewRuntimeMonitor creates a new runtime-based monitor.
-func NewRuntimeMonitor(s storage.Storage, p sender.Sender) RuntimeMonitor {
-	return &runtimeMonitor{Storage: s, Processor: p}
+// It reads every metric supported by runtime/metrics, which unlike
+// runtime.ReadMemStats does not stop the world.
+func NewRuntimeMonitor(s storage.Storage) RuntimeMonitor {
+	m := &runtimeMonitor{
+		Storage: s,
+		index:   make(map[string]int),
+		totals:  newCumulative(),
+	}
+
+	for _, d := range metrics.All() {
+		if d.Kind == metrics.KindBad {
+			continue
+		}
+		m.index[d.Name] = len(m.samples)
+		m.descs = append(m.descs, d)
+		m.samples = append(m.samples, metrics.Sample{Name: d.Name})
+	}
+
+	return m
 }
 
 // Collect collects runtime metrics.
@@ -26,61 +63,162 @@ func (m *runtimeMonitor) Collect() error {
 }
 
 // CollectRuntimeMetrics collects metrics from the Go runtime.
+//
+// Every runtime/metrics value is stored under its name converted to
+// go_<path>_<unit>: cumulative integers become counters, other scalars gauges
+// and histograms gauges labeled with a quantile since the process start.
+// The MemStats-named gauges are derived from the same sample.
 func (m *runtimeMonitor) CollectRuntimeMetrics() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	metrics.Read(m.samples)
+
 	m.collectRuntime()
+	m.collectMemStats()
 	m.collectRuntimeSystem()
 }
 
-// Dump sends collected metrics using the configured Sender.
-func (m *runtimeMonitor) Dump() error {
-	c := m.Storage.GetCounters()
-	g := m.Storage.GetGauges()
-	err := m.Processor.Process(c, g)
-	if err != nil {
-		return fmt.Errorf("error dumping metric: %w", err)
+func (m *runtimeMonitor) collectRuntime() {
+	for i, s := range m.samples {
+		name := runtimeMetricName(s.Name)
+
+		switch s.Value.Kind() {
+		case metrics.KindUint64:
+			v := s.Value.Uint64()
+			if m.descs[i].Cumulative {
+				if d, ok := m.totals.delta(name, v); ok {
+					m.Storage.AddCounter(name, d)
+				}
+				continue
+			}
+			m.Storage.SetGauge(name, float64(v))
+		case metrics.KindFloat64:
+			m.Storage.SetGauge(name, s.Value.Float64())
+		case metrics.KindFloat64Histogram:
+			h := s.Value.Float64Histogram()
+			for _, q := range histogramQuantiles {
+				l := map[string]string{"quantile": strconv.FormatFloat(q, 'f', -1, 64)}
+				m.Storage.SetGauge(labels.Format(name, l), histogramQuantile(h, q))
+			}
+		}
 	}
-	return nil
-}
 
-// SetProcessor updates the Sender used for dumping metrics.
-func (m *runtimeMonitor) SetProcessor(p sender.Sender) {
-	m.Processor = p
+	m.Storage.SetGauge("NumGoroutine", float64(runtime.NumGoroutine()))
+	m.Storage.SetGauge("GOMAXPROCS", float64(runtime.GOMAXPROCS(0)))
 }
 
-func (m *runtimeMonitor) collectRuntime() {
-	var rtm runtime.MemStats
-	runtime.ReadMemStats(&rtm)
-
-	m.Storage.SetGauge("BuckHashSys", float64(rtm.BuckHashSys))
-	m.Storage.SetGauge("Alloc", float64(rtm.Alloc))
-	m.Storage.SetGauge("Frees", float64(rtm.Frees))
-	m.Storage.SetGauge("GCCPUFraction", rtm.GCCPUFraction)
-	m.Storage.SetGauge("GCSys", float64(rtm.GCSys))
-	m.Storage.SetGauge("HeapAlloc", float64(rtm.HeapAlloc))
-	m.Storage.SetGauge("HeapIdle", float64(rtm.HeapIdle))
-	m.Storage.SetGauge("HeapInuse", float64(rtm.HeapInuse))
-	m.Storage.SetGauge("HeapObjects", float64(rtm.HeapObjects))
-	m.Storage.SetGauge("HeapReleased", float64(rtm.HeapReleased))
-	m.Storage.SetGauge("HeapSys", float64(rtm.HeapSys))
-	m.Storage.SetGauge("LastGC", float64(rtm.LastGC))
-	m.Storage.SetGauge("Lookups", float64(rtm.Lookups))
-	m.Storage.SetGauge("MCacheInuse", float64(rtm.MCacheInuse))
-	m.Storage.SetGauge("MCacheSys", float64(rtm.MCacheSys))
-	m.Storage.SetGauge("MSpanInuse", float64(rtm.MSpanInuse))
-	m.Storage.SetGauge("MSpanSys", float64(rtm.MSpanSys))
-	m.Storage.SetGauge("Mallocs", float64(rtm.Mallocs))
-	m.Storage.SetGauge("NextGC", float64(rtm.NextGC))
-	m.Storage.SetGauge("NumForcedGC", float64(rtm.NumForcedGC))
-	m.Storage.SetGauge("NumGC", float64(rtm.NumGC))
-	m.Storage.SetGauge("OtherSys", float64(rtm.OtherSys))
-	m.Storage.SetGauge("PauseTotalNs", float64(rtm.PauseTotalNs))
-	m.Storage.SetGauge("StackInuse", float64(rtm.StackInuse))
-	m.Storage.SetGauge("StackSys", float64(rtm.StackSys))
-	m.Storage.SetGauge("Sys", float64(rtm.Sys))
-	m.Storage.SetGauge("TotalAlloc", float64(rtm.TotalAlloc))
+// collectMemStats keeps reporting the gauges named after runtime.MemStats fields.
+func (m *runtimeMonitor) collectMemStats() {
+	heapObjects := m.value("/memory/classes/heap/objects:bytes")
+	heapUnused := m.value("/memory/classes/heap/unused:bytes")
+	heapFree := m.value("/memory/classes/heap/free:bytes")
+	heapReleased := m.value("/memory/classes/heap/released:bytes")
+	stacks := m.value("/memory/classes/heap/stacks:bytes")
+	mspan := m.value("/memory/classes/metadata/mspan/inuse:bytes")
+	mcache := m.value("/memory/classes/metadata/mcache/inuse:bytes")
+
+	var gcCPUFraction float64
+	if total := m.value("/cpu/classes/total:cpu-seconds"); total > 0 {
+		gcCPUFraction = m.value("/cpu/classes/gc/total:cpu-seconds") / total
+	}
+
+	var gc debug.GCStats
+	debug.ReadGCStats(&gc)
+	var lastGC float64
+	if !gc.LastGC.IsZero() {
+		lastGC = float64(gc.LastGC.UnixNano())
+	}
+
+	m.Storage.SetGauge("BuckHashSys", m.value("/memory/classes/profiling/buckets:bytes"))
+	m.Storage.SetGauge("Alloc", heapObjects)
+	m.Storage.SetGauge("Frees", m.value("/gc/heap/frees:objects"))
+	m.Storage.SetGauge("GCCPUFraction", gcCPUFraction)
+	m.Storage.SetGauge("GCSys", m.value("/memory/classes/metadata/other:bytes"))
+	m.Storage.SetGauge("HeapAlloc", heapObjects)
+	m.Storage.SetGauge("HeapIdle", heapFree+heapReleased)
+	m.Storage.SetGauge("HeapInuse", heapObjects+heapUnused)
+	m.Storage.SetGauge("HeapObjects", m.value("/gc/heap/objects:objects"))
+	m.Storage.SetGauge("HeapReleased", heapReleased)
+	m.Storage.SetGauge("HeapSys", heapObjects+heapUnused+heapFree+heapReleased)
+	m.Storage.SetGauge("LastGC", lastGC)
+	m.Storage.SetGauge("Lookups", 0)
+	m.Storage.SetGauge("MCacheInuse", mcache)
+	m.Storage.SetGauge("MCacheSys", mcache+m.value("/memory/classes/metadata/mcache/free:bytes"))
+	m.Storage.SetGauge("MSpanInuse", mspan)
+	m.Storage.SetGauge("MSpanSys", mspan+m.value("/memory/classes/metadata/mspan/free:bytes"))
+	m.Storage.SetGauge("Mallocs", m.value("/gc/heap/allocs:objects"))
+	m.Storage.SetGauge("NextGC", m.value("/gc/heap/goal:bytes"))
+	m.Storage.SetGauge("NumForcedGC", m.value("/gc/cycles/forced:gc-cycles"))
+	m.Storage.SetGauge("NumGC", m.value("/gc/cycles/total:gc-cycles"))
+	m.Storage.SetGauge("OtherSys", m.value("/memory/classes/other:bytes"))
+	m.Storage.SetGauge("PauseTotalNs", float64(gc.PauseTotal.Nanoseconds()))
+	m.Storage.SetGauge("StackInuse", stacks)
+	m.Storage.SetGauge("StackSys", stacks+m.value("/memory/classes/os-stacks:bytes"))
+	m.Storage.SetGauge("Sys", m.value("/memory/classes/total:bytes"))
+	m.Storage.SetGauge("TotalAlloc", m.value("/gc/heap/allocs:bytes"))
 }
 
 func (m *runtimeMonitor) collectRuntimeSystem() {
 	m.Storage.SetGauge("RandomValue", 1000*rand.Float64())
 	m.Storage.AddCounter("PollCount", 1)
 }
+
+// value returns the scalar value of the named runtime metric, or 0 if the
+// running Go version does not support it.
+func (m *runtimeMonitor) value(name string) float64 {
+	i, ok := m.index[name]
+	if !ok {
+		return 0
+	}
+
+	v := m.samples[i].Value
+	switch v.Kind() {
+	case metrics.KindUint64:
+		return float64(v.Uint64())
+	case metrics.KindFloat64:
+		return v.Float64()
+	}
+	return 0
+}
+
+// runtimeMetricName converts a runtime/metrics name such as
+// /gc/heap/allocs:bytes into go_gc_heap_allocs_bytes.
+func runtimeMetricName(name string) string {
+	var b strings.Builder
+	b.WriteString("go")
+	for _, r := range name {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			continue
+		}
+		b.WriteByte('_')
+	}
+	return b.String()
+}
+
+// histogramQuantile estimates the q-quantile of h as the upper bound of the
+// bucket holding it, falling back to the lower bound for the open last bucket.
+func histogramQuantile(h *metrics.Float64Histogram, q float64) float64 {
+	var total uint64
+	for _, c := range h.Counts {
+		total += c
+	}
+	if total == 0 {
+		return 0
+	}
+
+	target := uint64(math.Ceil(q * float64(total)))
+	var seen uint64
+	for i, c := range h.Counts {
+		seen += c
+		if seen < target || c == 0 {
+			continue
+		}
+		if upper := h.Buckets[i+1]; !math.IsInf(upper, 1) {
+			return upper
+		}
+		return h.Buckets[i]
+	}
+	return 0
+}
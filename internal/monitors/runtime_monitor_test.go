@@ -0,0 +1,53 @@
+package monitors
+
+import (
+	"math"
+	"runtime"
+	"runtime/metrics"
+	"testing"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuntimeMetricName(t *testing.T) {
+	require.Equal(t, "go_gc_heap_allocs_bytes", runtimeMetricName("/gc/heap/allocs:bytes"))
+	require.Equal(t, "go_cpu_classes_gc_total_cpu_seconds", runtimeMetricName("/cpu/classes/gc/total:cpu-seconds"))
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	h := &metrics.Float64Histogram{
+		Counts:  []uint64{5, 4, 0, 1},
+		Buckets: []float64{math.Inf(-1), 1, 2, 3, math.Inf(1)},
+	}
+
+	require.Equal(t, float64(1), histogramQuantile(h, 0.5))
+	require.Equal(t, float64(2), histogramQuantile(h, 0.9))
+	require.Equal(t, float64(3), histogramQuantile(h, 1))
+	require.Equal(t, float64(0), histogramQuantile(&metrics.Float64Histogram{Counts: []uint64{0}, Buckets: []float64{0, 1}}, 0.5))
+}
+
+func TestRuntimeMonitor_Collect(t *testing.T) {
+	st := storage.NewStorage()
+	m := NewRuntimeMonitor(st)
+
+	require.NoError(t, m.Collect())
+	runtime.GC()
+	require.NoError(t, m.Collect())
+
+	for _, name := range []string{"Alloc", "HeapSys", "NumGC", "NumGoroutine", "GOMAXPROCS", "go_sched_goroutines_goroutines"} {
+		_, err := st.GetGauge(name)
+		require.NoError(t, err, name)
+	}
+
+	g, err := st.GetGauge("GOMAXPROCS")
+	require.NoError(t, err)
+	require.Equal(t, float64(runtime.GOMAXPROCS(0)), g.Value)
+
+	_, err = st.GetGauge(`go_sched_latencies_seconds{quantile="0.99"}`)
+	require.NoError(t, err)
+
+	c, err := st.GetCounter("go_gc_cycles_total_gc_cycles")
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, c.Value, int64(1))
+}
@@ -1,26 +1,25 @@
 package network
 
 import (
-	"net"
 	"net/http"
 
 	"github.com/JinFuuMugen/ya_go_metrics/internal/logger"
 )
 
 func CheckValidSubnetMiddleware(subnet string) func(http.Handler) http.Handler {
-	var ipnet *net.IPNet
-
-	if subnet != "" {
-		_, parsed, err := net.ParseCIDR(subnet)
-		if err != nil {
-			return nil
-		}
-		ipnet = parsed
+	s, err := NewTrustedSubnet(subnet)
+	if err != nil {
+		return nil
 	}
+	return TrustedSubnetMiddleware(s)
+}
 
+// TrustedSubnetMiddleware rejects requests whose X-Real-IP header is outside of s.
+// Changes of s apply to the following requests.
+func TrustedSubnetMiddleware(s *TrustedSubnet) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-
+			ipnet := s.ipnet.Load()
 			if ipnet == nil {
 				next.ServeHTTP(w, r)
 				return
@@ -33,11 +32,7 @@ func CheckValidSubnetMiddleware(subnet string) func(http.Handler) http.Handler {
 				return
 			}
 
-			if v4 := reqIP.To4(); v4 != nil {
-				reqIP = v4
-			}
-
-			if !ipnet.Contains(reqIP) {
+			if !subnetContains(ipnet, reqIP) {
 				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
 				return
 			}
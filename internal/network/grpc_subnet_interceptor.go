@@ -15,21 +15,19 @@ const HeaderXRealIP = "x-real-ip"
 
 // SubnetUnaryInterceptor checks if request contains valid x-real-ip header
 func SubnetUnaryInterceptor(trustedSubnet string) grpc.UnaryServerInterceptor {
-	var (
-		ipnet  *net.IPNet
-		cfgErr error
-	)
-
-	if trustedSubnet != "" {
-		_, parsed, err := net.ParseCIDR(trustedSubnet)
-		if err != nil {
-			cfgErr = err
-			logger.Errorf("invalid trusted subnet CIDR %q: %v", trustedSubnet, err)
-		} else {
-			ipnet = parsed
+	s, err := NewTrustedSubnet(trustedSubnet)
+	if err != nil {
+		logger.Errorf("%v", err)
+		return func(context.Context, any, *grpc.UnaryServerInfo, grpc.UnaryHandler) (any, error) {
+			return nil, status.Error(codes.Internal, "invalid trusted subnet configuration")
 		}
 	}
+	return TrustedSubnetUnaryInterceptor(s)
+}
 
+// TrustedSubnetUnaryInterceptor rejects calls whose x-real-ip metadata is outside of s.
+// Changes of s apply to the following calls.
+func TrustedSubnetUnaryInterceptor(s *TrustedSubnet) grpc.UnaryServerInterceptor {
 	return func(
 		ctx context.Context,
 		req any,
@@ -37,9 +35,7 @@ func SubnetUnaryInterceptor(trustedSubnet string) grpc.UnaryServerInterceptor {
 		handler grpc.UnaryHandler,
 	) (any, error) {
 
-		if cfgErr != nil {
-			return nil, status.Error(codes.Internal, "invalid trusted subnet configuration")
-		}
+		ipnet := s.ipnet.Load()
 		if ipnet == nil {
 			return handler(ctx, req)
 		}
@@ -59,11 +55,7 @@ func SubnetUnaryInterceptor(trustedSubnet string) grpc.UnaryServerInterceptor {
 			return nil, status.Error(codes.PermissionDenied, "invalid x-real-ip metadata")
 		}
 
-		if v4 := ip.To4(); v4 != nil {
-			ip = v4
-		}
-
-		if !ipnet.Contains(ip) {
+		if !subnetContains(ipnet, ip) {
 			return nil, status.Error(codes.PermissionDenied, "ip not allowed")
 		}
 
@@ -0,0 +1,66 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"sync/atomic"
+)
+
+// TrustedSubnet holds the subnet metric updates are accepted from.
+// It can be replaced while requests are being checked against it.
+type TrustedSubnet struct {
+	ipnet atomic.Pointer[net.IPNet]
+}
+
+// NewTrustedSubnet creates a TrustedSubnet from a CIDR string.
+// An empty string allows every address.
+func NewTrustedSubnet(cidr string) (*TrustedSubnet, error) {
+	s := &TrustedSubnet{}
+	if err := s.Set(cidr); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Set replaces the subnet with the given CIDR. On error the subnet is left unchanged.
+func (s *TrustedSubnet) Set(cidr string) error {
+	ipnet, err := ParseSubnet(cidr)
+	if err != nil {
+		return err
+	}
+	s.ipnet.Store(ipnet)
+	return nil
+}
+
+// String returns the subnet in CIDR notation, or an empty string if every address is allowed.
+func (s *TrustedSubnet) String() string {
+	if ipnet := s.ipnet.Load(); ipnet != nil {
+		return ipnet.String()
+	}
+	return ""
+}
+
+// Allows reports whether ip belongs to the subnet.
+func (s *TrustedSubnet) Allows(ip net.IP) bool {
+	ipnet := s.ipnet.Load()
+	return ipnet == nil || subnetContains(ipnet, ip)
+}
+
+func subnetContains(ipnet *net.IPNet, ip net.IP) bool {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	return ipnet.Contains(ip)
+}
+
+// ParseSubnet parses a trusted subnet in CIDR notation. An empty string yields a nil subnet.
+func ParseSubnet(cidr string) (*net.IPNet, error) {
+	if cidr == "" {
+		return nil, nil
+	}
+	_, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted subnet CIDR %q: %w", cidr, err)
+	}
+	return ipnet, nil
+}
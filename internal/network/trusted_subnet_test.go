@@ -0,0 +1,59 @@
+package network
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestTrustedSubnet_SetAppliesToRunningMiddleware(t *testing.T) {
+	s, err := NewTrustedSubnet("10.0.0.0/8")
+	require.NoError(t, err)
+
+	h := TrustedSubnetMiddleware(s)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	do := func() int {
+		req := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+		req.Header.Set("X-Real-IP", "192.168.1.5")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	require.Equal(t, http.StatusForbidden, do())
+
+	require.NoError(t, s.Set("192.168.1.0/24"))
+	require.Equal(t, http.StatusOK, do())
+
+	require.Error(t, s.Set("not-a-cidr"))
+	require.Equal(t, "192.168.1.0/24", s.String())
+
+	require.NoError(t, s.Set(""))
+	require.True(t, s.Allows(net.ParseIP("8.8.8.8")))
+}
+
+func TestTrustedSubnet_SetAppliesToRunningInterceptor(t *testing.T) {
+	s, err := NewTrustedSubnet("")
+	require.NoError(t, err)
+
+	ic := TrustedSubnetUnaryInterceptor(s)
+	info := &grpc.UnaryServerInfo{FullMethod: "/metrics.Metrics/UpdateMetrics"}
+	handler := func(ctx context.Context, req any) (any, error) { return "ok", nil }
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(HeaderXRealIP, "192.168.1.5"))
+
+	_, err = ic(ctx, "req", info, handler)
+	require.NoError(t, err)
+
+	require.NoError(t, s.Set("10.0.0.0/8"))
+	_, err = ic(ctx, "req", info, handler)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+}
@@ -0,0 +1,181 @@
+// Package promtext parses the Prometheus text exposition format.
+package promtext
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Metric family types declared by # TYPE lines.
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+	TypeSummary   = "summary"
+	TypeUntyped   = "untyped"
+)
+
+// Sample is a single sample line.
+type Sample struct {
+	// Name is the sample name, including suffixes like _bucket or _count.
+	Name string
+
+	// Labels holds the sample labels, nil if there are none.
+	Labels map[string]string
+
+	// Value is the sample value.
+	Value float64
+
+	// Type is the type of the metric family the sample belongs to.
+	Type string
+}
+
+// familySuffixes are appended to family names by histogram, summary and counter samples.
+var familySuffixes = []string{"_bucket", "_count", "_sum", "_total", "_created"}
+
+// Parse reads all samples from r. Samples of families without a # TYPE line
+// are TypeUntyped. Timestamps are ignored.
+func Parse(r io.Reader) ([]Sample, error) {
+	types := make(map[string]string)
+	var samples []Sample
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if text == "" {
+			continue
+		}
+
+		if strings.HasPrefix(text, "#") {
+			fields := strings.Fields(text)
+			if len(fields) >= 4 && fields[1] == "TYPE" {
+				types[fields[2]] = fields[3]
+			}
+			continue
+		}
+
+		s, err := parseSample(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		s.Type = familyType(types, s.Name)
+		samples = append(samples, s)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read metrics: %w", err)
+	}
+
+	return samples, nil
+}
+
+func familyType(types map[string]string, name string) string {
+	if t, ok := types[name]; ok {
+		return t
+	}
+	for _, suffix := range familySuffixes {
+		if base, ok := strings.CutSuffix(name, suffix); ok {
+			if t, ok := types[base]; ok {
+				return t
+			}
+		}
+	}
+	return TypeUntyped
+}
+
+func parseSample(text string) (Sample, error) {
+	var s Sample
+
+	end := strings.IndexAny(text, "{ \t")
+	if end <= 0 {
+		return Sample{}, errors.New("missing value")
+	}
+	s.Name = text[:end]
+	rest := text[end:]
+
+	if rest[0] == '{' {
+		labels, n, err := parseLabels(rest)
+		if err != nil {
+			return Sample{}, err
+		}
+		s.Labels = labels
+		rest = rest[n:]
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 || len(fields) > 2 {
+		return Sample{}, fmt.Errorf("invalid sample %q", text)
+	}
+
+	v, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return Sample{}, fmt.Errorf("invalid value %q", fields[0])
+	}
+	s.Value = v
+
+	return s, nil
+}
+
+// parseLabels parses a {name="value",...} block at the start of text and
+// returns the labels and the length of the block.
+func parseLabels(text string) (map[string]string, int, error) {
+	labels := make(map[string]string)
+	i := 1
+
+	for {
+		for i < len(text) && (text[i] == ' ' || text[i] == ',') {
+			i++
+		}
+		if i >= len(text) {
+			return nil, 0, errors.New("unterminated label set")
+		}
+		if text[i] == '}' {
+			break
+		}
+
+		eq := strings.IndexByte(text[i:], '=')
+		if eq <= 0 {
+			return nil, 0, errors.New("invalid label")
+		}
+		name := strings.TrimSpace(text[i : i+eq])
+		i += eq + 1
+
+		if i >= len(text) || text[i] != '"' {
+			return nil, 0, fmt.Errorf("label %q: value must be quoted", name)
+		}
+		i++
+
+		var b strings.Builder
+		for ; i < len(text) && text[i] != '"'; i++ {
+			if text[i] != '\\' || i+1 >= len(text) {
+				b.WriteByte(text[i])
+				continue
+			}
+			i++
+			switch text[i] {
+			case 'n':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(text[i])
+			}
+		}
+		if i >= len(text) {
+			return nil, 0, fmt.Errorf("label %q: unterminated value", name)
+		}
+		i++
+
+		labels[name] = b.String()
+	}
+
+	if len(labels) == 0 {
+		labels = nil
+	}
+	return labels, i + 1, nil
+}
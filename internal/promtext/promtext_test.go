@@ -0,0 +1,61 @@
+package promtext
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const exposition = `# HELP http_requests_total Total requests.
+# TYPE http_requests_total counter
+http_requests_total{method="post",code="200"} 1027 1395066363000
+http_requests_total{method="post",code="400"}    3 1395066363000
+
+# TYPE queue_depth gauge
+queue_depth 12.5
+process_open_fds 9
+
+# TYPE rpc_duration_seconds histogram
+rpc_duration_seconds_bucket{le="0.05"} 24054
+rpc_duration_seconds_bucket{le="+Inf"} 144320
+rpc_duration_seconds_sum 53423
+rpc_duration_seconds_count 144320
+msdos_file_access_time_seconds{path="C:\\DIR\\FILE.TXT",error="Cannot find file:\n\"FILE.TXT\""} 1.458255915e9
+`
+
+func TestParse(t *testing.T) {
+	samples, err := Parse(strings.NewReader(exposition))
+	require.NoError(t, err)
+	require.Len(t, samples, 9)
+
+	require.Equal(t, Sample{
+		Name:   "http_requests_total",
+		Labels: map[string]string{"method": "post", "code": "400"},
+		Value:  3,
+		Type:   TypeCounter,
+	}, samples[1])
+
+	require.Equal(t, Sample{Name: "queue_depth", Value: 12.5, Type: TypeGauge}, samples[2])
+	require.Equal(t, TypeUntyped, samples[3].Type)
+
+	require.Equal(t, TypeHistogram, samples[5].Type)
+	require.Equal(t, float64(144320), samples[5].Value)
+	require.Equal(t, "+Inf", samples[5].Labels["le"])
+	require.Equal(t, TypeHistogram, samples[7].Type)
+
+	require.Equal(t, `C:\DIR\FILE.TXT`, samples[8].Labels["path"])
+	require.Equal(t, "Cannot find file:\n\"FILE.TXT\"", samples[8].Labels["error"])
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, in := range []string{
+		"no_value",
+		`bad{label=unquoted} 1`,
+		`bad{label="unterminated} 1`,
+		"bad_value abc",
+	} {
+		_, err := Parse(strings.NewReader(in))
+		require.Error(t, err, in)
+	}
+}
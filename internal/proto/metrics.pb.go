@@ -142,8 +142,11 @@ func (x *Metric) GetValue() float64 {
 
 // UpdateMetricsRequest содержит список метрик для обновления.
 type UpdateMetricsRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Metrics       []*Metric              `protobuf:"bytes,1,rep,name=metrics,proto3" json:"metrics,omitempty"`
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Metrics []*Metric              `protobuf:"bytes,1,rep,name=metrics,proto3" json:"metrics,omitempty"`
+	// Зашифрованный UpdateMetricsRequest (см. rsacrypto.Encrypt).
+	// Если задан, поле metrics не используется.
+	Envelope      []byte `protobuf:"bytes,2,opt,name=envelope,proto3" json:"envelope,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -185,16 +188,86 @@ func (x *UpdateMetricsRequest) GetMetrics() []*Metric {
 	return nil
 }
 
-// UpdateMetricsResponse — пустой ответ для подтверждения успешного обновления.
+func (x *UpdateMetricsRequest) GetEnvelope() []byte {
+	if x != nil {
+		return x.Envelope
+	}
+	return nil
+}
+
+// MetricStatus описывает результат применения одной метрики из батча.
+type MetricStatus struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`       // имя метрики
+	Ok            bool                   `protobuf:"varint,2,opt,name=ok,proto3" json:"ok,omitempty"`      // true, если метрика применена
+	Error         string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"` // причина отказа для невалидной метрики
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MetricStatus) Reset() {
+	*x = MetricStatus{}
+	mi := &file_internal_proto_metrics_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MetricStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MetricStatus) ProtoMessage() {}
+
+func (x *MetricStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_metrics_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MetricStatus.ProtoReflect.Descriptor instead.
+func (*MetricStatus) Descriptor() ([]byte, []int) {
+	return file_internal_proto_metrics_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *MetricStatus) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *MetricStatus) GetOk() bool {
+	if x != nil {
+		return x.Ok
+	}
+	return false
+}
+
+func (x *MetricStatus) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+// UpdateMetricsResponse подтверждает обновление.
+// В частичном режиме (metadata x-batch-mode: partial) содержит статус каждой метрики.
 type UpdateMetricsResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
+	Statuses      []*MetricStatus        `protobuf:"bytes,1,rep,name=statuses,proto3" json:"statuses,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *UpdateMetricsResponse) Reset() {
 	*x = UpdateMetricsResponse{}
-	mi := &file_internal_proto_metrics_proto_msgTypes[2]
+	mi := &file_internal_proto_metrics_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -206,7 +279,7 @@ func (x *UpdateMetricsResponse) String() string {
 func (*UpdateMetricsResponse) ProtoMessage() {}
 
 func (x *UpdateMetricsResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_proto_metrics_proto_msgTypes[2]
+	mi := &file_internal_proto_metrics_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -219,7 +292,14 @@ func (x *UpdateMetricsResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UpdateMetricsResponse.ProtoReflect.Descriptor instead.
 func (*UpdateMetricsResponse) Descriptor() ([]byte, []int) {
-	return file_internal_proto_metrics_proto_rawDescGZIP(), []int{2}
+	return file_internal_proto_metrics_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *UpdateMetricsResponse) GetStatuses() []*MetricStatus {
+	if x != nil {
+		return x.Statuses
+	}
+	return nil
 }
 
 var File_internal_proto_metrics_proto protoreflect.FileDescriptor
@@ -234,10 +314,16 @@ const file_internal_proto_metrics_proto_rawDesc = "" +
 	"\x05value\x18\x04 \x01(\x01R\x05value\"\x1f\n" +
 	"\x05MType\x12\t\n" +
 	"\x05GAUGE\x10\x00\x12\v\n" +
-	"\aCOUNTER\x10\x01\"A\n" +
+	"\aCOUNTER\x10\x01\"]\n" +
 	"\x14UpdateMetricsRequest\x12)\n" +
-	"\ametrics\x18\x01 \x03(\v2\x0f.metrics.MetricR\ametrics\"\x17\n" +
-	"\x15UpdateMetricsResponse2Y\n" +
+	"\ametrics\x18\x01 \x03(\v2\x0f.metrics.MetricR\ametrics\x12\x1a\n" +
+	"\benvelope\x18\x02 \x01(\fR\benvelope\"D\n" +
+	"\fMetricStatus\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x0e\n" +
+	"\x02ok\x18\x02 \x01(\bR\x02ok\x12\x14\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\"J\n" +
+	"\x15UpdateMetricsResponse\x121\n" +
+	"\bstatuses\x18\x01 \x03(\v2\x15.metrics.MetricStatusR\bstatuses2Y\n" +
 	"\aMetrics\x12N\n" +
 	"\rUpdateMetrics\x12\x1d.metrics.UpdateMetricsRequest\x1a\x1e.metrics.UpdateMetricsResponseB;Z9github.com/JinFuuMugen/ya_go_metrics/internal/proto;protob\x06proto3"
 
@@ -254,23 +340,25 @@ func file_internal_proto_metrics_proto_rawDescGZIP() []byte {
 }
 
 var file_internal_proto_metrics_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_internal_proto_metrics_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_internal_proto_metrics_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
 var file_internal_proto_metrics_proto_goTypes = []any{
 	(Metric_MType)(0),             // 0: metrics.Metric.MType
 	(*Metric)(nil),                // 1: metrics.Metric
 	(*UpdateMetricsRequest)(nil),  // 2: metrics.UpdateMetricsRequest
-	(*UpdateMetricsResponse)(nil), // 3: metrics.UpdateMetricsResponse
+	(*MetricStatus)(nil),          // 3: metrics.MetricStatus
+	(*UpdateMetricsResponse)(nil), // 4: metrics.UpdateMetricsResponse
 }
 var file_internal_proto_metrics_proto_depIdxs = []int32{
 	0, // 0: metrics.Metric.type:type_name -> metrics.Metric.MType
 	1, // 1: metrics.UpdateMetricsRequest.metrics:type_name -> metrics.Metric
-	2, // 2: metrics.Metrics.UpdateMetrics:input_type -> metrics.UpdateMetricsRequest
-	3, // 3: metrics.Metrics.UpdateMetrics:output_type -> metrics.UpdateMetricsResponse
-	3, // [3:4] is the sub-list for method output_type
-	2, // [2:3] is the sub-list for method input_type
-	2, // [2:2] is the sub-list for extension type_name
-	2, // [2:2] is the sub-list for extension extendee
-	0, // [0:2] is the sub-list for field type_name
+	3, // 2: metrics.UpdateMetricsResponse.statuses:type_name -> metrics.MetricStatus
+	2, // 3: metrics.Metrics.UpdateMetrics:input_type -> metrics.UpdateMetricsRequest
+	4, // 4: metrics.Metrics.UpdateMetrics:output_type -> metrics.UpdateMetricsResponse
+	4, // [4:5] is the sub-list for method output_type
+	3, // [3:4] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
 }
 
 func init() { file_internal_proto_metrics_proto_init() }
@@ -284,7 +372,7 @@ func file_internal_proto_metrics_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_internal_proto_metrics_proto_rawDesc), len(file_internal_proto_metrics_proto_rawDesc)),
 			NumEnums:      1,
-			NumMessages:   3,
+			NumMessages:   4,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
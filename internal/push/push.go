@@ -0,0 +1,190 @@
+// Package push implements the agent-local endpoint applications use to hand
+// metrics to the agent instead of sending them to the server themselves.
+package push
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/batch"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/compress"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/logger"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/models"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
+	"github.com/go-chi/chi/v5"
+)
+
+// socketMode restricts the Unix socket to the user running the agent.
+const socketMode = 0o600
+
+// Handler returns the push endpoint handler. POST /updates accepts the same
+// JSON array of metrics as the server, including gzip bodies and the partial
+// batch mode, and merges the metrics into st so they are sent with the
+// agent's next report.
+func Handler(st storage.Storage) http.Handler {
+	r := chi.NewRouter()
+	r.Use(compress.GzipMiddleware)
+
+	h := updateHandler(st)
+	r.Post("/updates", h)
+	r.Post("/updates/", h)
+
+	return r
+}
+
+func updateHandler(st storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			logger.Errorf("cannot read pushed metrics: %s", err)
+			http.Error(w, "cannot read request body", http.StatusBadRequest)
+			return
+		}
+
+		var metrics []models.Metrics
+		if err := json.Unmarshal(body, &metrics); err != nil {
+			logger.Errorf("cannot unmarshal pushed metrics: %s", err)
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		partial := batch.IsPartial(r.Header.Get(batch.ModeHeader))
+
+		results, _, err := batch.Apply(st, metrics, partial)
+		if err != nil {
+			logger.Errorf("invalid pushed metrics: %s", err)
+			if errors.Is(err, batch.ErrUnsupportedType) {
+				http.Error(w, err.Error(), http.StatusNotImplemented)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if !partial {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(results); err != nil {
+			logger.Errorf("cannot write response: %s", err)
+		}
+	}
+}
+
+// Server serves the push endpoint on a loopback TCP address, a Unix socket or both.
+type Server struct {
+	srv       *http.Server
+	listeners []net.Listener
+	socket    string
+}
+
+// Listen opens the listeners of the push endpoint. addr must be a loopback
+// address such as localhost:8081 so the endpoint is never exposed to the network.
+// An existing file at socket is removed first and the new socket is only
+// accessible by the agent's user. Empty values are skipped.
+func Listen(addr, socket string, h http.Handler) (*Server, error) {
+	s := &Server{
+		srv: &http.Server{
+			Handler:           h,
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       10 * time.Second,
+			WriteTimeout:      10 * time.Second,
+		},
+	}
+
+	if addr != "" {
+		if err := checkLoopback(addr); err != nil {
+			return nil, err
+		}
+
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("cannot listen on %s: %w", addr, err)
+		}
+		s.listeners = append(s.listeners, l)
+	}
+
+	if socket != "" {
+		if err := os.Remove(socket); err != nil && !errors.Is(err, os.ErrNotExist) {
+			s.closeListeners()
+			return nil, fmt.Errorf("cannot remove stale socket: %w", err)
+		}
+
+		l, err := net.Listen("unix", socket)
+		if err != nil {
+			s.closeListeners()
+			return nil, fmt.Errorf("cannot listen on %s: %w", socket, err)
+		}
+		s.listeners = append(s.listeners, l)
+		s.socket = socket
+
+		if err := os.Chmod(socket, socketMode); err != nil {
+			s.closeListeners()
+			return nil, fmt.Errorf("cannot restrict socket permissions: %w", err)
+		}
+	}
+
+	return s, nil
+}
+
+// Addrs returns the addresses the server listens on.
+func (s *Server) Addrs() []net.Addr {
+	addrs := make([]net.Addr, 0, len(s.listeners))
+	for _, l := range s.listeners {
+		addrs = append(addrs, l.Addr())
+	}
+	return addrs
+}
+
+// Serve starts serving on every listener in the background.
+func (s *Server) Serve() {
+	for _, l := range s.listeners {
+		go func() {
+			logger.Infof("push endpoint listening on %s", l.Addr())
+			if err := s.srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Errorf("push endpoint error: %s", err)
+			}
+		}()
+	}
+}
+
+// Shutdown stops accepting pushes and waits for in-flight requests.
+func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.srv.Shutdown(ctx)
+	if s.socket != "" {
+		_ = os.Remove(s.socket)
+	}
+	return err
+}
+
+func (s *Server) closeListeners() {
+	for _, l := range s.listeners {
+		l.Close()
+	}
+}
+
+func checkLoopback(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid push address %q: %w", addr, err)
+	}
+
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("push address %q is not a loopback address", addr)
+}
@@ -0,0 +1,71 @@
+package push
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/logger"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListen_RejectsNonLoopbackAddress(t *testing.T) {
+	_, err := Listen("0.0.0.0:0", "", http.NotFoundHandler())
+	require.Error(t, err)
+
+	_, err = Listen("example.com:8081", "", http.NotFoundHandler())
+	require.Error(t, err)
+}
+
+func TestServer_MergesPushedMetrics(t *testing.T) {
+	_ = logger.Init()
+
+	st := storage.NewStorage()
+	st.AddCounter("Requests", 2)
+
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	srv, err := Listen("127.0.0.1:0", socket, Handler(st))
+	require.NoError(t, err)
+	srv.Serve()
+	defer srv.Shutdown(context.Background())
+
+	fi, err := os.Stat(socket)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(socketMode), fi.Mode().Perm())
+
+	body := `[{"id":"Requests","type":"counter","delta":3},{"id":"QueueSize","type":"gauge","value":7}]`
+
+	resp, err := http.Post("http://"+srv.Addrs()[0].String()+"/updates/", "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	unixClient := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}}
+	resp, err = unixClient.Post("http://agent/updates", "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	c, err := st.GetCounter("Requests")
+	require.NoError(t, err)
+	require.Equal(t, int64(8), c.Value)
+
+	g, err := st.GetGauge("QueueSize")
+	require.NoError(t, err)
+	require.Equal(t, float64(7), g.Value)
+
+	resp, err = unixClient.Post("http://agent/updates", "application/json", strings.NewReader(`[{"id":"","type":"gauge","value":1}]`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,262 @@
+// Package relabel filters and rewrites agent metrics before they are sent.
+package relabel
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/labels"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
+)
+
+// Rule actions.
+const (
+	// ActionDrop removes matching metrics.
+	ActionDrop = "drop"
+
+	// ActionKeep removes metrics that do not match.
+	ActionKeep = "keep"
+
+	// ActionRename replaces the name of matching metrics with Replacement,
+	// which may refer to groups of Match as $1 or ${name}.
+	ActionRename = "rename"
+
+	// ActionLabel sets Labels on matching metrics. An empty value removes the label.
+	ActionLabel = "label"
+
+	// ActionCoerce converts matching metrics to Type. Only counters can be
+	// coerced, into gauges holding the increment of the report: a gauge is a
+	// current value and sending it as a counter would add it up on every report.
+	ActionCoerce = "coerce"
+)
+
+// Rule is a single step of the pipeline.
+type Rule struct {
+	// Action is one of the Action constants.
+	Action string `json:"action"`
+
+	// Match is a regular expression matched against the whole metric name,
+	// without labels. Empty matches every metric.
+	Match string `json:"match"`
+
+	// MatchLabels restricts the rule to metrics whose labels fully match the given regular expressions.
+	MatchLabels map[string]string `json:"match_labels"`
+
+	// Replacement is the new name for ActionRename.
+	Replacement string `json:"replacement"`
+
+	// Labels are the labels set by ActionLabel.
+	Labels map[string]string `json:"labels"`
+
+	// Type is the target type for ActionCoerce. Only gauge is supported.
+	Type string `json:"type"`
+}
+
+type rule struct {
+	Rule
+	match       *regexp.Regexp
+	matchLabels map[string]*regexp.Regexp
+}
+
+// Pipeline applies rules in order.
+type Pipeline struct {
+	rules []rule
+}
+
+// metric is a metric being rewritten by the pipeline.
+type metric struct {
+	name   string
+	labels map[string]string
+	mtype  string
+	value  float64
+}
+
+// New validates the rules and builds a pipeline.
+func New(rules []Rule) (*Pipeline, error) {
+	p := &Pipeline{rules: make([]rule, 0, len(rules))}
+
+	for i, r := range rules {
+		compiled, err := compile(r)
+		if err != nil {
+			return nil, fmt.Errorf("rule #%d: %w", i, err)
+		}
+		p.rules = append(p.rules, compiled)
+	}
+
+	return p, nil
+}
+
+func compile(r Rule) (rule, error) {
+	switch r.Action {
+	case ActionDrop, ActionKeep:
+	case ActionRename:
+		if r.Replacement == "" {
+			return rule{}, errors.New("rename needs a replacement")
+		}
+	case ActionLabel:
+		if len(r.Labels) == 0 {
+			return rule{}, errors.New("label needs labels")
+		}
+	case ActionCoerce:
+		if r.Type == storage.MetricTypeCounter {
+			return rule{}, errors.New("cannot coerce to counter: gauge values are not increments")
+		}
+		if r.Type != storage.MetricTypeGauge {
+			return rule{}, fmt.Errorf("cannot coerce to type %q", r.Type)
+		}
+	default:
+		return rule{}, fmt.Errorf("unknown action %q", r.Action)
+	}
+
+	c := rule{Rule: r}
+
+	var err error
+	if c.match, err = anchored(r.Match); err != nil {
+		return rule{}, fmt.Errorf("invalid match: %w", err)
+	}
+
+	if len(r.MatchLabels) > 0 {
+		c.matchLabels = make(map[string]*regexp.Regexp, len(r.MatchLabels))
+		for k, v := range r.MatchLabels {
+			if c.matchLabels[k], err = anchored(v); err != nil {
+				return rule{}, fmt.Errorf("invalid match for label %q: %w", k, err)
+			}
+		}
+	}
+
+	return c, nil
+}
+
+func anchored(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		pattern = ".*"
+	}
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
+
+// Apply runs the pipeline over a snapshot and returns the resulting metrics.
+// The input slices are not modified. Metrics renamed onto the same ID are
+// merged: counters are summed and the last gauge wins.
+func (p *Pipeline) Apply(counters []storage.Counter, gauges []storage.Gauge) ([]storage.Counter, []storage.Gauge) {
+	if len(p.rules) == 0 {
+		return counters, gauges
+	}
+
+	metrics := make([]metric, 0, len(counters)+len(gauges))
+	for _, c := range counters {
+		metrics = append(metrics, newMetric(c.Name, storage.MetricTypeCounter, float64(c.Value)))
+	}
+	for _, g := range gauges {
+		metrics = append(metrics, newMetric(g.Name, storage.MetricTypeGauge, g.Value))
+	}
+
+	for _, r := range p.rules {
+		metrics = r.apply(metrics)
+	}
+
+	return collect(metrics)
+}
+
+func newMetric(id, mtype string, value float64) metric {
+	name, l, err := labels.Parse(id)
+	if err != nil {
+		name, l = id, nil
+	}
+	return metric{name: name, labels: l, mtype: mtype, value: value}
+}
+
+func (r rule) matches(m metric) bool {
+	if !r.match.MatchString(m.name) {
+		return false
+	}
+	for k, re := range r.matchLabels {
+		if !re.MatchString(m.labels[k]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r rule) apply(metrics []metric) []metric {
+	out := metrics[:0]
+
+	for _, m := range metrics {
+		matched := r.matches(m)
+
+		switch r.Action {
+		case ActionDrop:
+			if matched {
+				continue
+			}
+		case ActionKeep:
+			if !matched {
+				continue
+			}
+		case ActionRename:
+			if matched {
+				m.name = r.match.ReplaceAllString(m.name, r.Replacement)
+			}
+		case ActionLabel:
+			if matched {
+				m.labels = withLabels(m.labels, r.Labels)
+			}
+		case ActionCoerce:
+			if matched {
+				m.mtype = r.Type
+			}
+		}
+
+		out = append(out, m)
+	}
+
+	return out
+}
+
+func withLabels(current, set map[string]string) map[string]string {
+	l := make(map[string]string, len(current)+len(set))
+	for k, v := range current {
+		l[k] = v
+	}
+	for k, v := range set {
+		if v == "" {
+			delete(l, k)
+			continue
+		}
+		l[k] = v
+	}
+	return l
+}
+
+func collect(metrics []metric) ([]storage.Counter, []storage.Gauge) {
+	counterIdx := make(map[string]int)
+	gaugeIdx := make(map[string]int)
+	var counters []storage.Counter
+	var gauges []storage.Gauge
+
+	for _, m := range metrics {
+		if m.name == "" {
+			continue
+		}
+		id := labels.Format(m.name, m.labels)
+
+		if m.mtype == storage.MetricTypeCounter {
+			if i, ok := counterIdx[id]; ok {
+				counters[i].Value += int64(m.value)
+				continue
+			}
+			counterIdx[id] = len(counters)
+			counters = append(counters, storage.Counter{Name: id, Type: storage.MetricTypeCounter, Value: int64(m.value)})
+			continue
+		}
+
+		if i, ok := gaugeIdx[id]; ok {
+			gauges[i].Value = m.value
+			continue
+		}
+		gaugeIdx[id] = len(gauges)
+		gauges = append(gauges, storage.Gauge{Name: id, Type: storage.MetricTypeGauge, Value: m.value})
+	}
+
+	return counters, gauges
+}
@@ -0,0 +1,82 @@
+package relabel
+
+import (
+	"testing"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func counter(name string, v int64) storage.Counter {
+	return storage.Counter{Name: name, Type: storage.MetricTypeCounter, Value: v}
+}
+
+func gauge(name string, v float64) storage.Gauge {
+	return storage.Gauge{Name: name, Type: storage.MetricTypeGauge, Value: v}
+}
+
+func TestNew_ValidatesRules(t *testing.T) {
+	for _, r := range []Rule{
+		{Action: "explode"},
+		{Action: ActionRename, Match: "x"},
+		{Action: ActionLabel},
+		{Action: ActionCoerce, Type: "histogram"},
+		{Action: ActionCoerce, Type: storage.MetricTypeCounter},
+		{Action: ActionDrop, Match: "("},
+		{Action: ActionDrop, MatchLabels: map[string]string{"a": "("}},
+	} {
+		_, err := New([]Rule{r})
+		require.Error(t, err, r.Action)
+	}
+}
+
+func TestPipeline_DropKeepRename(t *testing.T) {
+	p, err := New([]Rule{
+		{Action: ActionDrop, Match: "RandomValue|Lookups"},
+		{Action: ActionKeep, Match: "Heap.*|PollCount|Disk.*"},
+		{Action: ActionRename, Match: "(.*)", Replacement: "team_a_$1"},
+	})
+	require.NoError(t, err)
+
+	counters, gauges := p.Apply(
+		[]storage.Counter{counter("PollCount", 5)},
+		[]storage.Gauge{
+			gauge("RandomValue", 1),
+			gauge("HeapAlloc", 2),
+			gauge("Lookups", 0),
+			gauge("Sys", 3),
+			gauge(`DiskFree{mountpoint="/"}`, 4),
+		},
+	)
+
+	require.Equal(t, []storage.Counter{counter("team_a_PollCount", 5)}, counters)
+	require.Equal(t, []storage.Gauge{gauge("team_a_HeapAlloc", 2), gauge(`team_a_DiskFree{mountpoint="/"}`, 4)}, gauges)
+}
+
+func TestPipeline_LabelsAndCoerce(t *testing.T) {
+	p, err := New([]Rule{
+		{Action: ActionLabel, Labels: map[string]string{"team": "core"}},
+		{Action: ActionLabel, MatchLabels: map[string]string{"mountpoint": "/boot"}, Labels: map[string]string{"mountpoint": "", "boot": "true"}},
+		{Action: ActionCoerce, Match: "PollCount", Type: storage.MetricTypeGauge},
+	})
+	require.NoError(t, err)
+
+	in := []storage.Gauge{gauge("ErrorsSeen", 2.6), gauge(`DiskFree{mountpoint="/boot"}`, 1)}
+	counters, gauges := p.Apply([]storage.Counter{counter("PollCount", 5)}, in)
+
+	require.Empty(t, counters)
+	require.Equal(t, []storage.Gauge{
+		gauge(`PollCount{team="core"}`, 5),
+		gauge(`ErrorsSeen{team="core"}`, 2.6),
+		gauge(`DiskFree{boot="true",team="core"}`, 1),
+	}, gauges)
+	require.Equal(t, "ErrorsSeen", in[0].Name, "input must not be modified")
+}
+
+func TestPipeline_MergesCollisions(t *testing.T) {
+	p, err := New([]Rule{{Action: ActionRename, Match: "Requests(Get|Post)", Replacement: "Requests"}})
+	require.NoError(t, err)
+
+	counters, _ := p.Apply([]storage.Counter{counter("RequestsGet", 2), counter("RequestsPost", 3)}, nil)
+	require.Equal(t, []storage.Counter{counter("Requests", 5)}, counters)
+}
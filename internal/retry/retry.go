@@ -0,0 +1,198 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Policy describes how failed requests are retried.
+type Policy struct {
+	// MaxAttempts is the total number of attempts including the first one.
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the first retry.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the delay between attempts.
+	MaxBackoff time.Duration
+
+	// Multiplier grows the delay after every failed attempt.
+	Multiplier float64
+
+	// Jitter is the fraction of the delay randomly added or subtracted, from 0 to 1.
+	Jitter float64
+
+	// RetryableStatuses lists HTTP status codes worth retrying.
+	// gRPC status codes are mapped onto their HTTP equivalents.
+	RetryableStatuses []int
+
+	// Budget limits the total time of all attempts including the delays
+	// between them. Zero means no limit.
+	Budget time.Duration
+}
+
+// DefaultRetryableStatuses lists the status codes retried by default.
+var DefaultRetryableStatuses = []int{
+	http.StatusRequestTimeout,
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// DefaultPolicy returns the policy used when nothing is configured.
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxAttempts:       4,
+		InitialBackoff:    time.Second,
+		MaxBackoff:        5 * time.Second,
+		Multiplier:        2,
+		Jitter:            0.2,
+		RetryableStatuses: DefaultRetryableStatuses,
+	}
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks err as not worth retrying.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// StatusError is returned for requests answered with a non-successful status.
+type StatusError struct {
+	// Code is the HTTP status code.
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("server responded with status %d", e.Code)
+}
+
+// CheckStatus returns nil for successful status codes, a retryable *StatusError
+// for codes listed in RetryableStatuses and a permanent one otherwise.
+func (p Policy) CheckStatus(code int) error {
+	if code < http.StatusBadRequest {
+		return nil
+	}
+
+	err := &StatusError{Code: code}
+	if slices.Contains(p.RetryableStatuses, code) {
+		return err
+	}
+	return Permanent(err)
+}
+
+// HTTPStatusFromGRPC maps a gRPC status code onto the equivalent HTTP status.
+func HTTPStatusFromGRPC(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument, codes.OutOfRange, codes.FailedPrecondition:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Canceled:
+		return http.StatusRequestTimeout
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Backoff returns the delay to wait after the given failed attempt, starting from 1.
+func (p Policy) Backoff(attempt int) time.Duration {
+	d := float64(p.InitialBackoff) * math.Pow(max(p.Multiplier, 1), float64(attempt-1))
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+
+	if p.Jitter > 0 {
+		d += d * p.Jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(d)
+}
+
+// WithBudget returns a context ending when the budget of p is spent. Attempts
+// should be made with it, so the last one is cut short as well.
+func (p Policy) WithBudget(parent context.Context) (context.Context, context.CancelFunc) {
+	if p.Budget <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, p.Budget)
+}
+
+// Do calls fn until it succeeds, returns a permanent error, the attempts are
+// exhausted or ctx is done. No retry is started when ctx ends before its delay
+// is over. The attempt number passed to fn starts from 1.
+func (p Policy) Do(ctx context.Context, fn func(attempt int) error) error {
+	attempts := max(p.MaxAttempts, 1)
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		err = fn(attempt)
+		if err == nil || IsPermanent(err) {
+			return err
+		}
+		if attempt == attempts {
+			break
+		}
+
+		delay := p.Backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+			return fmt.Errorf("retry budget spent after %d attempts: %w", attempt, err)
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return fmt.Errorf("retry aborted after %d attempts: %w", attempt, err)
+		case <-t.C:
+		}
+	}
+
+	return fmt.Errorf("giving up after %d attempts: %w", attempts, err)
+}
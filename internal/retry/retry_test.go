@@ -0,0 +1,139 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func testPolicy() Policy {
+	p := DefaultPolicy()
+	p.InitialBackoff = time.Millisecond
+	p.MaxBackoff = 4 * time.Millisecond
+	p.Jitter = 0
+	return p
+}
+
+func TestPolicy_Do_RetriesUntilSuccess(t *testing.T) {
+	calls := 0
+	err := testPolicy().Do(context.Background(), func(attempt int) error {
+		calls++
+		require.Equal(t, calls, attempt)
+		if attempt < 3 {
+			return errors.New("temporary")
+		}
+		return nil
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, 3, calls)
+}
+
+func TestPolicy_Do_GivesUpAfterMaxAttempts(t *testing.T) {
+	calls := 0
+	errTemp := errors.New("temporary")
+
+	err := testPolicy().Do(context.Background(), func(int) error {
+		calls++
+		return errTemp
+	})
+
+	require.ErrorIs(t, err, errTemp)
+	require.Equal(t, 4, calls)
+}
+
+func TestPolicy_Do_StopsOnPermanentError(t *testing.T) {
+	calls := 0
+	err := testPolicy().Do(context.Background(), func(int) error {
+		calls++
+		return Permanent(errors.New("bad request"))
+	})
+
+	require.Error(t, err)
+	require.True(t, IsPermanent(err))
+	require.Equal(t, 1, calls)
+}
+
+func TestPolicy_Do_StopsOnContextDone(t *testing.T) {
+	p := testPolicy()
+	p.InitialBackoff = time.Hour
+	p.MaxBackoff = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := 0
+	err := p.Do(ctx, func(int) error {
+		calls++
+		return errors.New("temporary")
+	})
+
+	require.Error(t, err)
+	require.Equal(t, 1, calls)
+}
+
+func TestPolicy_Do_StopsWhenBudgetIsSpent(t *testing.T) {
+	p := testPolicy()
+	p.InitialBackoff = 20 * time.Millisecond
+	p.MaxBackoff = 20 * time.Millisecond
+	p.MaxAttempts = 100
+	p.Budget = 50 * time.Millisecond
+
+	ctx, cancel := p.WithBudget(context.Background())
+	defer cancel()
+
+	start := time.Now()
+	calls := 0
+	err := p.Do(ctx, func(int) error {
+		calls++
+		return errors.New("temporary")
+	})
+
+	require.Error(t, err)
+	require.Less(t, time.Since(start), p.Budget)
+	require.Less(t, calls, 4, "no retry is started that cannot finish its delay in the budget")
+}
+
+func TestPolicy_Backoff(t *testing.T) {
+	p := Policy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second, Multiplier: 2}
+
+	require.Equal(t, time.Second, p.Backoff(1))
+	require.Equal(t, 2*time.Second, p.Backoff(2))
+	require.Equal(t, 4*time.Second, p.Backoff(3))
+	require.Equal(t, 5*time.Second, p.Backoff(4))
+	require.Equal(t, 5*time.Second, p.Backoff(10))
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := p.Backoff(1)
+		require.GreaterOrEqual(t, d, 500*time.Millisecond)
+		require.LessOrEqual(t, d, 1500*time.Millisecond)
+	}
+}
+
+func TestPolicy_CheckStatus(t *testing.T) {
+	p := DefaultPolicy()
+
+	require.NoError(t, p.CheckStatus(http.StatusOK))
+
+	err := p.CheckStatus(http.StatusServiceUnavailable)
+	require.Error(t, err)
+	require.False(t, IsPermanent(err))
+
+	for _, code := range []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotImplemented} {
+		err := p.CheckStatus(code)
+		require.Error(t, err)
+		require.True(t, IsPermanent(err), "status %d", code)
+	}
+}
+
+func TestHTTPStatusFromGRPC(t *testing.T) {
+	require.Equal(t, http.StatusServiceUnavailable, HTTPStatusFromGRPC(codes.Unavailable))
+	require.Equal(t, http.StatusForbidden, HTTPStatusFromGRPC(codes.PermissionDenied))
+	require.Equal(t, http.StatusBadRequest, HTTPStatusFromGRPC(codes.InvalidArgument))
+}
@@ -2,49 +2,87 @@ package sender
 
 import (
 	"context"
+	"crypto/rsa"
+	"errors"
 	"fmt"
-	"time"
 
+	"github.com/JinFuuMugen/ya_go_metrics/internal/balancer"
 	"github.com/JinFuuMugen/ya_go_metrics/internal/config"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/cryptography/rsacrypto"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/idempotency"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/logger"
 	"github.com/JinFuuMugen/ya_go_metrics/internal/network"
 	pb "github.com/JinFuuMugen/ya_go_metrics/internal/proto"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/retry"
 	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 type grpcSender struct {
-	addr   string
-	client pb.MetricsClient
-	conn   *grpc.ClientConn
+	pool      *balancer.Pool
+	conns     map[string]*grpc.ClientConn
+	clients   map[string]pb.MetricsClient
+	policy    retry.Policy
+	publicKey *rsa.PublicKey
 }
 
 // NewGRPCSender creates a new GRPCSender instance using the provided configuration.
-func NewGRPCSender(cfg config.AgentConfig) (*grpcSender, error) {
-	conn, err := grpc.NewClient(
-		cfg.GRPCAddr,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("grpc dial: %w", err)
+// cfg.GRPCAddr may list several comma separated replicas, balanced and
+// retried like in NewSender.
+// When publicKey is set, requests are sent encrypted in their envelope field.
+func NewGRPCSender(cfg config.AgentConfig, publicKey *rsa.PublicKey) (*grpcSender, error) {
+	s := &grpcSender{
+		conns:     make(map[string]*grpc.ClientConn),
+		clients:   make(map[string]pb.MetricsClient),
+		policy:    cfg.RetryPolicy(),
+		publicKey: publicKey,
+	}
+
+	replicas := newPool(cfg.GRPCAddr, 0)
+	for _, addr := range replicas.Addrs() {
+		conn, err := grpc.NewClient(
+			addr,
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("grpc dial: %w", err)
+		}
+		s.conns[addr] = conn
+		s.clients[addr] = pb.NewMetricsClient(conn)
 	}
 
-	return &grpcSender{
-		addr:   cfg.GRPCAddr,
-		conn:   conn,
-		client: pb.NewMetricsClient(conn),
-	}, nil
+	replicas.Start(cfg.HealthCheckInterval)
+	s.pool = replicas
+
+	return s, nil
 }
 
 func (s *grpcSender) Close() error {
-	if s.conn != nil {
-		return s.conn.Close()
+	if s.pool != nil {
+		s.pool.Stop()
+	}
+
+	var errs []error
+	for _, conn := range s.conns {
+		errs = append(errs, conn.Close())
 	}
-	return nil
+	return errors.Join(errs...)
 }
 
+// Process sends metrics to the server with the UpdateMetrics call.
 func (s *grpcSender) Process(counters []storage.Counter, gauges []storage.Gauge) error {
+	return s.ProcessWithKey(idempotency.NewKey(), counters, gauges)
+}
+
+// ProcessWithKey is like Process but uses the given idempotency key.
+func (s *grpcSender) ProcessWithKey(key string, counters []storage.Counter, gauges []storage.Gauge) error {
 	req := &pb.UpdateMetricsRequest{
 		Metrics: make([]*pb.Metric, 0, len(counters)+len(gauges)),
 	}
@@ -69,23 +107,67 @@ func (s *grpcSender) Process(counters []storage.Counter, gauges []storage.Gauge)
 		})
 	}
 
-	ip, err := network.OutboundIPTo(s.addr)
+	if s.publicKey != nil {
+		data, err := proto.Marshal(req)
+		if err != nil {
+			return fmt.Errorf("cannot serialize metrics: %w", err)
+		}
+		envelope, err := rsacrypto.Encrypt(s.publicKey, data)
+		if err != nil {
+			return fmt.Errorf("failed encrypt data: %w", err)
+		}
+		req = &pb.UpdateMetricsRequest{Envelope: envelope}
+	}
+
+	ctx, cancel := s.policy.WithBudget(context.Background())
+	defer cancel()
+
+	addr := s.pool.Pick()
+	return s.policy.Do(ctx, func(attempt int) error {
+		err := s.send(ctx, addr, key, req)
+		reportHealth(s.pool, addr, err)
+		if err != nil {
+			logger.Warnf("attempt %d: %s", attempt, err)
+			if isNotDelivered(err) {
+				addr = s.pool.Pick()
+			}
+		}
+		return err
+	})
+}
+
+func (s *grpcSender) send(ctx context.Context, addr, key string, req *pb.UpdateMetricsRequest) error {
+	ip, err := network.OutboundIPTo(addr)
 	if err != nil {
-		return fmt.Errorf("cannot determine outbound ip: %w", err)
+		return notDelivered(fmt.Errorf("cannot determine outbound ip: %w", err))
 	}
 
 	md := metadata.New(map[string]string{
-		"x-real-ip": ip.String(),
+		"x-real-ip":             ip.String(),
+		idempotency.MetadataKey: key,
 	})
 
-	ctx, cancel := context.WithTimeout(metadata.NewOutgoingContext(context.Background(), md), 5*time.Second)
+	ctx, cancel := context.WithTimeout(metadata.NewOutgoingContext(ctx, md), requestTimeout)
 	defer cancel()
 
-	_, err = s.client.UpdateMetrics(ctx, req)
-	if err != nil {
-		return fmt.Errorf("grpc UpdateMetrics: %w", err)
+	// Without a ready connection the call waits for one to be made and fails
+	// with Unavailable when it cannot be, before the request is sent.
+	connected := s.conns[addr].GetState() == connectivity.Ready
+
+	_, err = s.clients[addr].UpdateMetrics(ctx, req)
+	if err == nil {
+		return nil
+	}
+
+	code := status.Code(err)
+	err = fmt.Errorf("grpc UpdateMetrics to %s: %w", addr, err)
+	if retry.IsPermanent(s.policy.CheckStatus(retry.HTTPStatusFromGRPC(code))) {
+		return retry.Permanent(err)
+	}
+	if !connected && code == codes.Unavailable {
+		return notDelivered(err)
 	}
-	return nil
+	return err
 }
 
 // Compress to fullfill Sender interface
@@ -0,0 +1,101 @@
+package sender
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"net"
+	"testing"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/cryptography/rsacrypto"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/logger"
+	pb "github.com/JinFuuMugen/ya_go_metrics/internal/proto"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+type recordingMetricsServer struct {
+	pb.UnimplementedMetricsServer
+	requests chan *pb.UpdateMetricsRequest
+}
+
+func (s *recordingMetricsServer) UpdateMetrics(_ context.Context, req *pb.UpdateMetricsRequest) (*pb.UpdateMetricsResponse, error) {
+	s.requests <- req
+	return &pb.UpdateMetricsResponse{}, nil
+}
+
+// startGRPCServer serves a recording metrics server on a local port behind interceptors.
+func startGRPCServer(t *testing.T, interceptors ...grpc.UnaryServerInterceptor) (string, chan *pb.UpdateMetricsRequest) {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(interceptors...))
+	rec := &recordingMetricsServer{requests: make(chan *pb.UpdateMetricsRequest, 1)}
+	pb.RegisterMetricsServer(srv, rec)
+
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	return lis.Addr().String(), rec.requests
+}
+
+func TestGRPCSender_EncryptsRequests(t *testing.T) {
+	_ = logger.Init()
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	t.Run("without interceptor the server only sees the envelope", func(t *testing.T) {
+		addr, requests := startGRPCServer(t)
+		cfg := testConfig("")
+		cfg.GRPCAddr = addr
+
+		s, err := NewGRPCSender(cfg, &priv.PublicKey)
+		require.NoError(t, err)
+		defer s.Close()
+
+		require.NoError(t, s.Process(largeMetrics(2000)))
+		req := <-requests
+		require.Empty(t, req.GetMetrics())
+		require.NotEmpty(t, req.GetEnvelope())
+	})
+
+	t.Run("decrypted by the server interceptor", func(t *testing.T) {
+		addr, requests := startGRPCServer(t, rsacrypto.DecryptUnaryInterceptor(rsacrypto.NewDecrypter(priv)))
+		cfg := testConfig("")
+		cfg.GRPCAddr = addr
+
+		s, err := NewGRPCSender(cfg, &priv.PublicKey)
+		require.NoError(t, err)
+		defer s.Close()
+
+		for _, n := range []int{1, 30, 2000} {
+			require.NoError(t, s.Process(largeMetrics(n)))
+			req := <-requests
+			require.Len(t, req.GetMetrics(), 2*n)
+			require.Empty(t, req.GetEnvelope())
+		}
+	})
+}
+
+func TestGRPCSender_FailsOverWhenReplicaIsUnreachable(t *testing.T) {
+	_ = logger.Init()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	downAddr := lis.Addr().String()
+	require.NoError(t, lis.Close())
+
+	addr, requests := startGRPCServer(t)
+	cfg := testConfig("")
+	cfg.GRPCAddr = downAddr + "," + addr
+
+	s, err := NewGRPCSender(cfg, nil)
+	require.NoError(t, err)
+	defer s.Close()
+
+	require.NoError(t, s.Process(testMetrics()))
+	require.Len(t, (<-requests).GetMetrics(), 2)
+}
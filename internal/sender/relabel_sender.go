@@ -0,0 +1,34 @@
+package sender
+
+import (
+	"github.com/JinFuuMugen/ya_go_metrics/internal/idempotency"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/relabel"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
+)
+
+type relabelSender struct {
+	next     Sender
+	pipeline *relabel.Pipeline
+}
+
+// NewRelabelSender wraps next so that every report passes through the relabel pipeline first.
+// Callers keep settling counter deltas against their own, unmodified snapshot.
+func NewRelabelSender(next Sender, p *relabel.Pipeline) Sender {
+	return &relabelSender{next: next, pipeline: p}
+}
+
+// Process rewrites the metrics and sends them.
+func (s *relabelSender) Process(counters []storage.Counter, gauges []storage.Gauge) error {
+	return s.ProcessWithKey(idempotency.NewKey(), counters, gauges)
+}
+
+// ProcessWithKey is like Process but uses the given idempotency key.
+func (s *relabelSender) ProcessWithKey(key string, counters []storage.Counter, gauges []storage.Gauge) error {
+	counters, gauges = s.pipeline.Apply(counters, gauges)
+	return s.next.ProcessWithKey(key, counters, gauges)
+}
+
+// Compress delegates to the wrapped Sender.
+func (s *relabelSender) Compress(data []byte) ([]byte, error) {
+	return s.next.Compress(data)
+}
@@ -0,0 +1,43 @@
+package sender
+
+import (
+	"testing"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/relabel"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+type captureSender struct {
+	key    string
+	gauges []storage.Gauge
+}
+
+func (c *captureSender) Process(counters []storage.Counter, gauges []storage.Gauge) error {
+	return c.ProcessWithKey("", counters, gauges)
+}
+
+func (c *captureSender) ProcessWithKey(key string, _ []storage.Counter, gauges []storage.Gauge) error {
+	c.key = key
+	c.gauges = gauges
+	return nil
+}
+
+func (c *captureSender) Compress(b []byte) ([]byte, error) { return b, nil }
+
+func TestRelabelSender(t *testing.T) {
+	p, err := relabel.New([]relabel.Rule{{Action: relabel.ActionDrop, Match: "RandomValue"}})
+	require.NoError(t, err)
+
+	next := &captureSender{}
+	s := NewRelabelSender(next, p)
+
+	gauges := []storage.Gauge{
+		{Name: "RandomValue", Type: storage.MetricTypeGauge, Value: 1},
+		{Name: "Alloc", Type: storage.MetricTypeGauge, Value: 2},
+	}
+	require.NoError(t, s.ProcessWithKey("key-1", nil, gauges))
+
+	require.Equal(t, "key-1", next.key)
+	require.Equal(t, []storage.Gauge{{Name: "Alloc", Type: storage.MetricTypeGauge, Value: 2}}, next.gauges)
+}
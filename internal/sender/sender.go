@@ -3,21 +3,31 @@ package sender
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"crypto/rsa"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
+	"time"
 
+	"github.com/JinFuuMugen/ya_go_metrics/internal/balancer"
 	"github.com/JinFuuMugen/ya_go_metrics/internal/config"
 	"github.com/JinFuuMugen/ya_go_metrics/internal/cryptography"
 	"github.com/JinFuuMugen/ya_go_metrics/internal/cryptography/rsacrypto"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/idempotency"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/logger"
 	"github.com/JinFuuMugen/ya_go_metrics/internal/models"
 	"github.com/JinFuuMugen/ya_go_metrics/internal/network"
 	"github.com/JinFuuMugen/ya_go_metrics/internal/pool"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/retry"
 	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
 	"github.com/go-resty/resty/v2"
 )
 
+const requestTimeout = 5 * time.Second
+
 var bufferPool = pool.New(func() *bytes.Buffer {
 	return &bytes.Buffer{}
 })
@@ -25,19 +35,39 @@ var bufferPool = pool.New(func() *bytes.Buffer {
 // Sender defines an interface for sending collected metrics.
 type Sender interface {
 	Process([]storage.Counter, []storage.Gauge) error
+
+	// ProcessWithKey sends metrics using the given idempotency key,
+	// so a batch resent later is not applied twice by the server.
+	ProcessWithKey(key string, counters []storage.Counter, gauges []storage.Gauge) error
+
 	Compress(data []byte) ([]byte, error)
 }
 
 type values struct {
-	addr      string
+	pool      *balancer.Pool
 	client    *resty.Client
 	key       string
 	publicKey *rsa.PublicKey
+	policy    retry.Policy
 }
 
 // NewSender creates a new Sender instance using the provided configuration.
+// cfg.Addr may list several comma separated replicas: reports are spread
+// across the healthy ones. Failed reports are retried according to
+// cfg.RetryPolicy on the same replica, as only it knows whether it applied the
+// report already; the next replica is only tried when the request never
+// reached the server. A report spooled and resent later may go to another
+// replica, so replicas must share their idempotency store to apply it once.
 func NewSender(cfg config.AgentConfig, publicKey *rsa.PublicKey) *values {
-	return &values{cfg.Addr, resty.New(), cfg.Key, publicKey}
+	client := resty.New().SetTimeout(requestTimeout)
+	pool := newPool(cfg.Addr, cfg.HealthCheckInterval)
+	return &values{pool, client, cfg.Key, publicKey, cfg.RetryPolicy()}
+}
+
+// Close stops the health checks of the server replicas.
+func (v *values) Close() error {
+	v.pool.Stop()
+	return nil
 }
 
 // Compress compresses data using gzip algorithm.
@@ -63,6 +93,11 @@ func (v *values) Compress(data []byte) ([]byte, error) {
 
 // Process serializes metrics, compresses them and sends to the server.
 func (v *values) Process(counters []storage.Counter, gauges []storage.Gauge) error {
+	return v.ProcessWithKey(idempotency.NewKey(), counters, gauges)
+}
+
+// ProcessWithKey is like Process but uses the given idempotency key.
+func (v *values) ProcessWithKey(key string, counters []storage.Counter, gauges []storage.Gauge) error {
 	var err error
 
 	var metrics []models.Metrics
@@ -104,33 +139,104 @@ func (v *values) Process(counters []storage.Counter, gauges []storage.Gauge) err
 		}
 		encrypted = true
 	}
-	url := "http://" + v.addr + "/updates/"
-
-	req := v.client.R().
-		SetHeader("Content-Type", "application/json").
-		SetHeader("Content-Encoding", "gzip").
-		SetBody(dataToSend)
+	headers := map[string]string{
+		"Content-Type":        "application/json",
+		"Content-Encoding":    "gzip",
+		idempotency.HeaderKey: key,
+	}
 
 	if encrypted {
-		req.SetHeader("X-Encrypted", "rsa")
+		headers["X-Encrypted"] = "rsa"
 	}
 
 	if v.key != "" {
 		hash := cryptography.GetHMACSHA256(jsonData, v.key)
-		req.SetHeader("HashSHA256", hex.EncodeToString(hash))
+		headers["HashSHA256"] = hex.EncodeToString(hash)
 	}
 
-	ip, err := network.OutboundIPTo(v.addr)
+	ctx, cancel := v.policy.WithBudget(context.Background())
+	defer cancel()
+
+	addr := v.pool.Pick()
+	return v.policy.Do(ctx, func(attempt int) error {
+		err := v.send(ctx, addr, headers, dataToSend)
+		reportHealth(v.pool, addr, err)
+		if err != nil {
+			logger.Warnf("attempt %d: %s", attempt, err)
+			if isNotDelivered(err) {
+				addr = v.pool.Pick()
+			}
+		}
+		return err
+	})
+}
+
+func (v *values) send(ctx context.Context, addr string, headers map[string]string, body []byte) error {
+	ip, err := network.OutboundIPTo(addr)
 	if err != nil {
-		return fmt.Errorf("cannot determine outbound ip: %w", err)
+		return notDelivered(fmt.Errorf("cannot determine outbound ip: %w", err))
 	}
 
-	req.SetHeader("X-Real-IP", ip.String())
-
-	_, err = req.Post(url)
+	resp, err := v.client.R().
+		SetContext(ctx).
+		SetHeaders(headers).
+		SetHeader("X-Real-IP", ip.String()).
+		SetBody(body).
+		Post("http://" + addr + "/updates/")
 	if err != nil {
-		return fmt.Errorf("cannot send HTTP-Request: %w", err)
+		err = fmt.Errorf("cannot send HTTP-Request to %s: %w", addr, err)
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return notDelivered(err)
+		}
+		return err
 	}
 
-	return nil
+	return v.policy.CheckStatus(resp.StatusCode())
+}
+
+// newPool creates the replica pool for a comma separated address list and
+// starts its health checks.
+func newPool(addrs string, healthCheckInterval time.Duration) *balancer.Pool {
+	list := balancer.SplitAddrs(addrs)
+	if len(list) == 0 {
+		list = []string{addrs}
+	}
+
+	replicas, _ := balancer.New(list)
+	replicas.Start(healthCheckInterval)
+	return replicas
+}
+
+// reportHealth marks addr unhealthy after errors that are not the server
+// rejecting the request, so the next reports go to another replica.
+func reportHealth(replicas *balancer.Pool, addr string, err error) {
+	if retry.IsPermanent(err) {
+		err = nil
+	}
+	replicas.Report(addr, err)
+}
+
+// notDeliveredError marks an error showing the request never reached the
+// server, so it can be retried on another replica without risking it being
+// applied twice.
+type notDeliveredError struct {
+	err error
+}
+
+func (e *notDeliveredError) Error() string {
+	return e.err.Error()
+}
+
+func (e *notDeliveredError) Unwrap() error {
+	return e.err
+}
+
+func notDelivered(err error) error {
+	return &notDeliveredError{err: err}
+}
+
+func isNotDelivered(err error) bool {
+	var e *notDeliveredError
+	return errors.As(err, &e)
 }
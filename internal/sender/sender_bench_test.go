@@ -9,8 +9,9 @@ import (
 
 func BenchmarkSenderProcess(b *testing.B) {
 	cfg := config.AgentConfig{
-		Addr: "localhost:8080",
-		Key:  "",
+		Addr:             "localhost:8080",
+		Key:              "",
+		RetryMaxAttempts: 1,
 	}
 
 	s := NewSender(cfg, nil)
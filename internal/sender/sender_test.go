@@ -0,0 +1,216 @@
+package sender
+
+import (
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/config"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/cryptography/rsacrypto"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/idempotency"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/logger"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/models"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/retry"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func testConfig(addr string) config.AgentConfig {
+	return config.AgentConfig{
+		Addr:                addr,
+		RetryMaxAttempts:    3,
+		RetryInitialBackoff: time.Millisecond,
+		RetryMaxBackoff:     2 * time.Millisecond,
+		RetryStatuses:       retry.DefaultRetryableStatuses,
+	}
+}
+
+func testMetrics() ([]storage.Counter, []storage.Gauge) {
+	return []storage.Counter{{Name: "PollCount", Type: storage.MetricTypeCounter, Value: 1}},
+		[]storage.Gauge{{Name: "Alloc", Type: storage.MetricTypeGauge, Value: 1.5}}
+}
+
+func TestSenderProcess_RetriesRetryableStatus(t *testing.T) {
+	_ = logger.Init()
+
+	var calls int32
+	var mu sync.Mutex
+	keys := map[string]struct{}{}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys[r.Header.Get(idempotency.HeaderKey)] = struct{}{}
+		mu.Unlock()
+
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	s := NewSender(testConfig(strings.TrimPrefix(srv.URL, "http://")), nil)
+
+	require.NoError(t, s.Process(testMetrics()))
+	require.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	require.Len(t, keys, 1, "retries must reuse the idempotency key")
+}
+
+func TestSenderProcess_PermanentStatusIsNotRetried(t *testing.T) {
+	_ = logger.Init()
+
+	for _, code := range []int{http.StatusBadRequest, http.StatusForbidden} {
+		var calls int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(code)
+		}))
+
+		s := NewSender(testConfig(strings.TrimPrefix(srv.URL, "http://")), nil)
+
+		err := s.Process(testMetrics())
+		require.Error(t, err)
+		require.True(t, retry.IsPermanent(err))
+		require.Equal(t, int32(1), atomic.LoadInt32(&calls), "status %d", code)
+
+		srv.Close()
+	}
+}
+
+func TestSenderProcess_GivesUpAfterMaxAttempts(t *testing.T) {
+	_ = logger.Init()
+
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	s := NewSender(testConfig(strings.TrimPrefix(srv.URL, "http://")), nil)
+
+	err := s.Process(testMetrics())
+	require.Error(t, err)
+
+	var statusErr *retry.StatusError
+	require.ErrorAs(t, err, &statusErr)
+	require.Equal(t, http.StatusBadGateway, statusErr.Code)
+	require.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestSenderProcess_RetriesConnectionErrors(t *testing.T) {
+	_ = logger.Init()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	addr := strings.TrimPrefix(srv.URL, "http://")
+	srv.Close()
+
+	s := NewSender(testConfig(addr), nil)
+
+	err := s.Process(testMetrics())
+	require.Error(t, err)
+	require.False(t, retry.IsPermanent(err))
+}
+
+func TestSenderProcess_FailsOverToHealthyReplica(t *testing.T) {
+	_ = logger.Init()
+
+	down := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	downAddr := strings.TrimPrefix(down.URL, "http://")
+	down.Close()
+
+	var upCalls int32
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		atomic.AddInt32(&upCalls, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer up.Close()
+
+	s := NewSender(testConfig(downAddr+","+strings.TrimPrefix(up.URL, "http://")), nil)
+	defer s.Close()
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, s.Process(testMetrics()))
+	}
+
+	require.Equal(t, int32(3), atomic.LoadInt32(&upCalls), "a refused connection fails over to the next replica")
+}
+
+func TestSenderProcess_RetriesOnTheSameReplica(t *testing.T) {
+	_ = logger.Init()
+
+	var firstCalls, secondCalls int32
+	first := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if atomic.AddInt32(&firstCalls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer first.Close()
+
+	second := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		atomic.AddInt32(&secondCalls, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer second.Close()
+
+	addrs := strings.TrimPrefix(first.URL, "http://") + "," + strings.TrimPrefix(second.URL, "http://")
+	s := NewSender(testConfig(addrs), nil)
+	defer s.Close()
+
+	require.NoError(t, s.Process(testMetrics()))
+	require.Equal(t, int32(3), atomic.LoadInt32(&firstCalls), "a replica that got the request keeps its retries")
+	require.Zero(t, atomic.LoadInt32(&secondCalls))
+}
+
+// largeMetrics returns n counters and n gauges, a report far larger than a single RSA block.
+func largeMetrics(n int) ([]storage.Counter, []storage.Gauge) {
+	counters := make([]storage.Counter, n)
+	gauges := make([]storage.Gauge, n)
+	for i := range counters {
+		counters[i] = storage.Counter{Name: fmt.Sprintf("Counter%d", i), Type: storage.MetricTypeCounter, Value: int64(i)}
+		gauges[i] = storage.Gauge{Name: fmt.Sprintf("Gauge%d", i), Type: storage.MetricTypeGauge, Value: float64(i) / 3}
+	}
+	return counters, gauges
+}
+
+func TestSenderProcess_EncryptsLargeReports(t *testing.T) {
+	_ = logger.Init()
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var received []models.Metrics
+	srv := httptest.NewServer(rsacrypto.CryptoMiddleware(priv)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received = nil
+		if err := json.NewDecoder(zr).Decode(&received); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	})))
+	defer srv.Close()
+
+	s := NewSender(testConfig(strings.TrimPrefix(srv.URL, "http://")), &priv.PublicKey)
+	defer s.Close()
+
+	for _, n := range []int{1, 30, 2000} {
+		require.NoError(t, s.Process(largeMetrics(n)))
+		require.Len(t, received, 2*n)
+	}
+}
@@ -0,0 +1,65 @@
+package spool
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/idempotency"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/logger"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/retry"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/sender"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
+)
+
+type spoolSender struct {
+	next  sender.Sender
+	queue *Queue
+}
+
+// NewSender wraps next so that reports it fails to deliver are stored in the queue
+// and resent in order, with their original idempotency keys, before the next report.
+// A spooled report counts as handed off and is not reported as an error, so callers
+// do not carry its counter deltas over into the next report.
+// Reports rejected by the server as invalid are dropped instead of spooled.
+func NewSender(next sender.Sender, queue *Queue) sender.Sender {
+	return &spoolSender{next: next, queue: queue}
+}
+
+// Process drains the queue and sends the metrics, spooling them on failure.
+func (s *spoolSender) Process(counters []storage.Counter, gauges []storage.Gauge) error {
+	return s.ProcessWithKey(idempotency.NewKey(), counters, gauges)
+}
+
+// ProcessWithKey is like Process but uses the given idempotency key.
+func (s *spoolSender) ProcessWithKey(key string, counters []storage.Counter, gauges []storage.Gauge) error {
+	b := Batch{Key: key, Created: time.Now(), Counters: counters, Gauges: gauges}
+
+	err := s.queue.Drain(s.send)
+	if err == nil {
+		err = s.next.ProcessWithKey(key, counters, gauges)
+		if err == nil || retry.IsPermanent(err) {
+			return err
+		}
+		b.Attempted = true
+	}
+
+	if err := s.queue.Push(b); err != nil {
+		return fmt.Errorf("cannot spool report: %w", err)
+	}
+	logger.Warnf("report spooled until the server is reachable: %s", err)
+	return nil
+}
+
+// Compress delegates to the wrapped Sender.
+func (s *spoolSender) Compress(data []byte) ([]byte, error) {
+	return s.next.Compress(data)
+}
+
+func (s *spoolSender) send(b Batch) error {
+	err := s.next.ProcessWithKey(b.Key, b.Counters, b.Gauges)
+	if retry.IsPermanent(err) {
+		logger.Errorf("dropping spooled report rejected by server: %s", err)
+		return nil
+	}
+	return err
+}
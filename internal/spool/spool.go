@@ -0,0 +1,281 @@
+package spool
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/logger"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
+)
+
+const fileExt = ".json"
+
+// Batch is a report that could not be delivered.
+type Batch struct {
+	// Key is the idempotency key the batch was first sent with.
+	Key string `json:"key"`
+
+	// Created is the time the batch was first sent.
+	Created time.Time `json:"created"`
+
+	// Counters holds counter deltas of the batch.
+	Counters []storage.Counter `json:"counters"`
+
+	// Gauges holds gauge values of the batch.
+	Gauges []storage.Gauge `json:"gauges"`
+
+	// Attempted is set once sending the batch failed without a known outcome.
+	// The server may have applied it, so it is only ever resent on its own,
+	// under its own key, and never merged.
+	Attempted bool `json:"attempted,omitempty"`
+}
+
+// Queue is a bounded on-disk FIFO of undelivered batches.
+// Every batch is stored in its own file named so that lexical order is the push order.
+type Queue struct {
+	mu       sync.Mutex
+	dir      string
+	maxBytes int64
+	maxAge   time.Duration
+	seq      uint64
+}
+
+// Open opens the queue stored in dir, creating the directory if needed.
+// maxBytes caps the total size of the queue and maxAge drops batches too old to be useful;
+// zero values disable the corresponding cap.
+func Open(dir string, maxBytes int64, maxAge time.Duration) (*Queue, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("cannot create spool dir: %w", err)
+	}
+
+	return &Queue{dir: dir, maxBytes: maxBytes, maxAge: maxAge}, nil
+}
+
+// SetLimits replaces the size and age caps of the queue. They apply from the
+// next Push or Drain.
+func (q *Queue) SetLimits(maxBytes int64, maxAge time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.maxBytes, q.maxAge = maxBytes, maxAge
+}
+
+// Len returns the number of queued batches.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	files, err := q.files()
+	if err != nil {
+		return 0
+	}
+	return len(files)
+}
+
+// Push appends a batch to the end of the queue.
+// When the queue grows over its size cap the oldest two batches that were never
+// attempted are merged: counter deltas are summed and the newest gauge values
+// kept, so no increments are lost. Without such a pair the oldest batch is dropped.
+func (q *Queue) Push(b Batch) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.seq++
+	name := fmt.Sprintf("%020d-%06d%s", time.Now().UnixNano(), q.seq%1000000, fileExt)
+	if err := q.write(name, b); err != nil {
+		return err
+	}
+
+	return q.compact()
+}
+
+// Drain sends queued batches in order, removing each one after it was sent.
+// It stops at the first failed batch, which stays at the head of the queue
+// marked as attempted.
+// Batches older than the age cap are discarded without sending.
+func (q *Queue) Drain(send func(Batch) error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	files, err := q.files()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range files {
+		b, err := q.read(name)
+		if err != nil {
+			logger.Errorf("dropping unreadable spooled batch %s: %s", name, err)
+			q.remove(name)
+			continue
+		}
+
+		if q.maxAge > 0 && time.Since(b.Created) > q.maxAge {
+			logger.Warnf("dropping spooled batch %s older than %s", name, q.maxAge)
+			q.remove(name)
+			continue
+		}
+
+		if err := send(b); err != nil {
+			if !b.Attempted {
+				b.Attempted = true
+				if werr := q.write(name, b); werr != nil {
+					logger.Errorf("cannot mark spooled batch %s attempted: %s", name, werr)
+				}
+			}
+			return err
+		}
+		q.remove(name)
+	}
+
+	return nil
+}
+
+func (q *Queue) compact() error {
+	if q.maxBytes <= 0 {
+		return nil
+	}
+
+	for {
+		files, err := q.files()
+		if err != nil {
+			return err
+		}
+
+		var total int64
+		for _, name := range files {
+			if fi, err := os.Stat(filepath.Join(q.dir, name)); err == nil {
+				total += fi.Size()
+			}
+		}
+		if total <= q.maxBytes {
+			return nil
+		}
+
+		if err := q.mergeOldest(files); err != nil {
+			return err
+		}
+	}
+}
+
+// mergeOldest merges the oldest two adjacent batches that were never attempted,
+// or drops the oldest batch if there are none.
+func (q *Queue) mergeOldest(files []string) error {
+	var older Batch
+	for i, name := range files {
+		b, err := q.read(name)
+		if err != nil {
+			q.remove(name)
+			return nil
+		}
+
+		if i > 0 && CanMerge(older, b) {
+			if err := q.write(name, Merge(older, b)); err != nil {
+				return err
+			}
+			q.remove(files[i-1])
+			return nil
+		}
+		older = b
+	}
+
+	logger.Warnf("dropping spooled batch %s to stay under spool size cap", files[0])
+	q.remove(files[0])
+	return nil
+}
+
+// CanMerge reports whether two batches may be merged: neither may have been
+// attempted, as the server could have applied it under its own key already.
+func CanMerge(older, newer Batch) bool {
+	return !older.Attempted && !newer.Attempted
+}
+
+// Merge combines two batches into one, older first. Both must satisfy CanMerge.
+// Counter deltas are summed and gauges take the newer value.
+// The result keeps the creation time of the older batch and gets the key of the newer one.
+func Merge(older, newer Batch) Batch {
+	counters := make(map[string]int64)
+	var counterOrder []string
+	for _, c := range slices.Concat(older.Counters, newer.Counters) {
+		if _, ok := counters[c.Name]; !ok {
+			counterOrder = append(counterOrder, c.Name)
+		}
+		counters[c.Name] += c.Value
+	}
+
+	gauges := make(map[string]float64)
+	var gaugeOrder []string
+	for _, g := range slices.Concat(older.Gauges, newer.Gauges) {
+		if _, ok := gauges[g.Name]; !ok {
+			gaugeOrder = append(gaugeOrder, g.Name)
+		}
+		gauges[g.Name] = g.Value
+	}
+
+	merged := Batch{Key: newer.Key, Created: older.Created}
+	for _, name := range counterOrder {
+		merged.Counters = append(merged.Counters, storage.Counter{Name: name, Type: storage.MetricTypeCounter, Value: counters[name]})
+	}
+	for _, name := range gaugeOrder {
+		merged.Gauges = append(merged.Gauges, storage.Gauge{Name: name, Type: storage.MetricTypeGauge, Value: gauges[name]})
+	}
+	return merged
+}
+
+func (q *Queue) files() ([]string, error) {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read spool dir: %w", err)
+	}
+
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), fileExt) {
+			files = append(files, e.Name())
+		}
+	}
+	slices.Sort(files)
+	return files, nil
+}
+
+func (q *Queue) read(name string) (Batch, error) {
+	data, err := os.ReadFile(filepath.Join(q.dir, name))
+	if err != nil {
+		return Batch{}, fmt.Errorf("cannot read spooled batch: %w", err)
+	}
+
+	var b Batch
+	if err := json.Unmarshal(data, &b); err != nil {
+		return Batch{}, fmt.Errorf("cannot decode spooled batch: %w", err)
+	}
+	return b, nil
+}
+
+func (q *Queue) write(name string, b Batch) error {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return fmt.Errorf("cannot encode batch: %w", err)
+	}
+
+	tmp := filepath.Join(q.dir, name+".tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("cannot write spooled batch: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(q.dir, name)); err != nil {
+		return fmt.Errorf("cannot commit spooled batch: %w", err)
+	}
+	return nil
+}
+
+func (q *Queue) remove(name string) {
+	if err := os.Remove(filepath.Join(q.dir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.Errorf("cannot remove spooled batch %s: %s", name, err)
+	}
+}
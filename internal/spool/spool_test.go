@@ -0,0 +1,258 @@
+package spool
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/JinFuuMugen/ya_go_metrics/internal/logger"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/retry"
+	"github.com/JinFuuMugen/ya_go_metrics/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func batch(key string, delta int64, gauge float64) Batch {
+	return Batch{
+		Key:      key,
+		Created:  time.Now(),
+		Counters: []storage.Counter{{Name: "PollCount", Type: storage.MetricTypeCounter, Value: delta}},
+		Gauges:   []storage.Gauge{{Name: "Alloc", Type: storage.MetricTypeGauge, Value: gauge}},
+	}
+}
+
+func TestQueue_DrainInOrder(t *testing.T) {
+	_ = logger.Init()
+
+	q, err := Open(t.TempDir(), 0, 0)
+	require.NoError(t, err)
+
+	for _, k := range []string{"a", "b", "c"} {
+		require.NoError(t, q.Push(batch(k, 1, 1)))
+	}
+	require.Equal(t, 3, q.Len())
+
+	var keys []string
+	err = q.Drain(func(b Batch) error {
+		if b.Key == "b" && len(keys) == 1 {
+			keys = append(keys, "fail")
+			return errors.New("down")
+		}
+		keys = append(keys, b.Key)
+		return nil
+	})
+	require.Error(t, err)
+	require.Equal(t, 2, q.Len(), "failed batch stays at the head")
+
+	keys = nil
+	require.NoError(t, q.Drain(func(b Batch) error {
+		keys = append(keys, b.Key)
+		return nil
+	}))
+	require.Equal(t, []string{"b", "c"}, keys)
+	require.Equal(t, 0, q.Len())
+}
+
+func TestQueue_SurvivesReopen(t *testing.T) {
+	_ = logger.Init()
+
+	dir := t.TempDir()
+	q, err := Open(dir, 0, 0)
+	require.NoError(t, err)
+	require.NoError(t, q.Push(batch("a", 3, 1)))
+
+	q, err = Open(dir, 0, 0)
+	require.NoError(t, err)
+
+	var got []Batch
+	require.NoError(t, q.Drain(func(b Batch) error {
+		got = append(got, b)
+		return nil
+	}))
+	require.Len(t, got, 1)
+	require.Equal(t, int64(3), got[0].Counters[0].Value)
+}
+
+func TestQueue_DropsOldBatches(t *testing.T) {
+	_ = logger.Init()
+
+	q, err := Open(t.TempDir(), 0, time.Minute)
+	require.NoError(t, err)
+
+	old := batch("old", 1, 1)
+	old.Created = time.Now().Add(-time.Hour)
+	require.NoError(t, q.Push(old))
+	require.NoError(t, q.Push(batch("new", 1, 1)))
+
+	var keys []string
+	require.NoError(t, q.Drain(func(b Batch) error {
+		keys = append(keys, b.Key)
+		return nil
+	}))
+	require.Equal(t, []string{"new"}, keys)
+}
+
+func TestQueue_SetLimits(t *testing.T) {
+	_ = logger.Init()
+
+	q, err := Open(t.TempDir(), 0, 0)
+	require.NoError(t, err)
+
+	old := batch("old", 1, 1)
+	old.Created = time.Now().Add(-time.Hour)
+	require.NoError(t, q.Push(old))
+
+	q.SetLimits(0, time.Minute)
+	require.NoError(t, q.Drain(func(Batch) error {
+		t.Fatal("batches older than the new age cap must be dropped")
+		return nil
+	}))
+	require.Equal(t, 0, q.Len())
+}
+
+func TestQueue_SizeCapMergesCounters(t *testing.T) {
+	_ = logger.Init()
+
+	q, err := Open(t.TempDir(), 0, 0)
+	require.NoError(t, err)
+
+	require.NoError(t, q.Push(batch("a", 2, 1)))
+	require.NoError(t, q.Push(batch("b", 3, 2)))
+
+	q.maxBytes = 250
+	require.NoError(t, q.Push(batch("c", 4, 3)))
+	require.Less(t, q.Len(), 3)
+
+	var total int64
+	var lastGauge float64
+	require.NoError(t, q.Drain(func(b Batch) error {
+		for _, c := range b.Counters {
+			total += c.Value
+		}
+		lastGauge = b.Gauges[0].Value
+		return nil
+	}))
+	require.Equal(t, int64(9), total, "counter deltas must be preserved when merging")
+	require.Equal(t, 3.0, lastGauge)
+}
+
+func TestQueue_SizeCapNeverMergesAttemptedBatches(t *testing.T) {
+	_ = logger.Init()
+
+	q, err := Open(t.TempDir(), 0, 0)
+	require.NoError(t, err)
+
+	require.NoError(t, q.Push(batch("a", 2, 1)))
+	require.Error(t, q.Drain(func(Batch) error { return errors.New("timeout") }))
+
+	attempted := batch("b", 3, 2)
+	attempted.Attempted = true
+	require.NoError(t, q.Push(attempted))
+	require.NoError(t, q.Push(batch("c", 4, 3)))
+
+	// Less room than one more batch, so pushing another merges exactly once.
+	var size int64
+	entries, err := os.ReadDir(q.dir)
+	require.NoError(t, err)
+	for _, e := range entries {
+		fi, err := e.Info()
+		require.NoError(t, err)
+		size += fi.Size()
+	}
+	q.maxBytes = size + size/6
+
+	require.NoError(t, q.Push(batch("d", 5, 4)))
+	require.Equal(t, 3, q.Len())
+
+	sent := map[string]int64{}
+	require.NoError(t, q.Drain(func(b Batch) error {
+		sent[b.Key] = b.Counters[0].Value
+		return nil
+	}))
+	require.Equal(t, map[string]int64{"a": 2, "b": 3, "d": 9}, sent,
+		"attempted batches keep their keys and deltas, only never sent ones are merged")
+}
+
+func TestQueue_DrainMarksFailedBatchAttempted(t *testing.T) {
+	_ = logger.Init()
+
+	dir := t.TempDir()
+	q, err := Open(dir, 0, 0)
+	require.NoError(t, err)
+	require.NoError(t, q.Push(batch("a", 1, 1)))
+	require.Error(t, q.Drain(func(Batch) error { return errors.New("timeout") }))
+
+	reopened, err := Open(dir, 0, 0)
+	require.NoError(t, err)
+	require.NoError(t, reopened.Drain(func(b Batch) error {
+		require.True(t, b.Attempted)
+		return nil
+	}))
+}
+
+func TestMerge(t *testing.T) {
+	m := Merge(batch("a", 2, 1), batch("b", 3, 2))
+	require.Equal(t, "b", m.Key)
+	require.Equal(t, int64(5), m.Counters[0].Value)
+	require.Equal(t, 2.0, m.Gauges[0].Value)
+}
+
+type fakeSender struct {
+	err  error
+	keys []string
+}
+
+func (f *fakeSender) Process(c []storage.Counter, g []storage.Gauge) error {
+	return f.ProcessWithKey("", c, g)
+}
+
+func (f *fakeSender) ProcessWithKey(key string, _ []storage.Counter, _ []storage.Gauge) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.keys = append(f.keys, key)
+	return nil
+}
+
+func (f *fakeSender) Compress(b []byte) ([]byte, error) { return b, nil }
+
+func TestSender_SpoolsAndReplaysWithOriginalKeys(t *testing.T) {
+	_ = logger.Init()
+
+	q, err := Open(t.TempDir(), 0, 0)
+	require.NoError(t, err)
+
+	next := &fakeSender{err: errors.New("down")}
+	s := NewSender(next, q)
+
+	c, g := batch("", 1, 1).Counters, batch("", 1, 1).Gauges
+
+	require.NoError(t, s.ProcessWithKey("k1", c, g), "spooled reports count as handed off")
+	require.NoError(t, s.ProcessWithKey("k2", c, g))
+	require.Equal(t, 2, q.Len())
+
+	var attempted []bool
+	require.Error(t, q.Drain(func(b Batch) error {
+		attempted = append(attempted, b.Attempted)
+		return errors.New("down")
+	}))
+	require.Equal(t, []bool{true}, attempted, "a report whose send failed is marked attempted")
+
+	next.err = nil
+	require.NoError(t, s.ProcessWithKey("k3", c, g))
+	require.Equal(t, []string{"k1", "k2", "k3"}, next.keys)
+	require.Equal(t, 0, q.Len())
+}
+
+func TestSender_PermanentErrorsAreNotSpooled(t *testing.T) {
+	_ = logger.Init()
+
+	q, err := Open(t.TempDir(), 0, 0)
+	require.NoError(t, err)
+
+	s := NewSender(&fakeSender{err: retry.Permanent(errors.New("bad request"))}, q)
+
+	c, g := batch("", 1, 1).Counters, batch("", 1, 1).Gauges
+	require.Error(t, s.Process(c, g))
+	require.Equal(t, 0, q.Len())
+}
@@ -2,13 +2,17 @@ package storage
 
 import (
 	"errors"
+	"sync"
 )
 
 // MemStorage is an in-memory storage for metrics.
+// It is safe for concurrent use.
 //
 //generate:reset
 //go:generate go run ../../cmd/reset/main.go
 type MemStorage struct {
+	mu sync.RWMutex
+
 	// GaugeMap stores gauge metrics by name.
 	GaugeMap map[string]float64
 
@@ -18,11 +22,17 @@ type MemStorage struct {
 
 // SetGauge sets the value of a gauge metric.
 func (ms *MemStorage) SetGauge(key string, value float64) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
 	ms.GaugeMap[key] = value
 }
 
 // AddCounter increments the value of a counter metric.
 func (ms *MemStorage) AddCounter(key string, value int64) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
 	_, keyExists := ms.CounterMap[key]
 	if keyExists {
 		ms.CounterMap[key] += value
@@ -31,8 +41,40 @@ func (ms *MemStorage) AddCounter(key string, value int64) {
 	}
 }
 
+// Update adds the counters and sets the gauges under a single lock.
+func (ms *MemStorage) Update(counters []Counter, gauges []Gauge) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	for _, c := range counters {
+		ms.CounterMap[c.Name] += c.Value
+	}
+	for _, g := range gauges {
+		ms.GaugeMap[g.Name] = g.Value
+	}
+}
+
+// Snapshot returns all stored counters and gauges under a single lock.
+func (ms *MemStorage) Snapshot() ([]Counter, []Gauge) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	counters := make([]Counter, 0, len(ms.CounterMap))
+	for k, v := range ms.CounterMap {
+		counters = append(counters, Counter{Name: k, Type: MetricTypeCounter, Value: v})
+	}
+	gauges := make([]Gauge, 0, len(ms.GaugeMap))
+	for k, v := range ms.GaugeMap {
+		gauges = append(gauges, Gauge{Name: k, Type: MetricTypeGauge, Value: v})
+	}
+	return counters, gauges
+}
+
 // GetGauges returns all stored gauge metrics.
 func (ms *MemStorage) GetGauges() []Gauge {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
 	var gauges []Gauge
 	for k, v := range ms.GaugeMap {
 		gauges = append(gauges, Gauge{Name: k, Type: MetricTypeGauge, Value: v})
@@ -42,6 +84,9 @@ func (ms *MemStorage) GetGauges() []Gauge {
 
 // GetCounters returns all stored counter metrics.
 func (ms *MemStorage) GetCounters() []Counter {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
 	var counters []Counter
 	for k, v := range ms.CounterMap {
 		counters = append(counters, Counter{Name: k, Type: MetricTypeCounter, Value: v})
@@ -51,6 +96,9 @@ func (ms *MemStorage) GetCounters() []Counter {
 
 // GetCounter returns a counter metric by name.
 func (ms *MemStorage) GetCounter(k string) (Counter, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
 	c, exists := ms.CounterMap[k]
 	if exists {
 		return Counter{Name: k, Type: MetricTypeCounter, Value: c}, nil
@@ -61,6 +109,9 @@ func (ms *MemStorage) GetCounter(k string) (Counter, error) {
 
 // GetGauge returns a gauge metric by name.
 func (ms *MemStorage) GetGauge(k string) (Gauge, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
 	g, exists := ms.GaugeMap[k]
 	if exists {
 		return Gauge{Name: k, Type: MetricTypeGauge, Value: g}, nil
@@ -38,6 +38,13 @@ type (
 		GetGauges() []Gauge
 		GetCounter(string) (Counter, error)
 		GetGauge(string) (Gauge, error)
+
+		// Update adds the counters and sets the gauges as a single change:
+		// readers see either none or all of them.
+		Update([]Counter, []Gauge)
+
+		// Snapshot returns all counters and gauges as of a single point in time.
+		Snapshot() ([]Counter, []Gauge)
 	}
 
 	// Counter represents a counter metric.
@@ -121,3 +128,11 @@ func GetCounters() []Counter {
 func GetGauges() []Gauge {
 	return defaultStorage.GetGauges()
 }
+
+func Update(counters []Counter, gauges []Gauge) {
+	defaultStorage.Update(counters, gauges)
+}
+
+func Snapshot() ([]Counter, []Gauge) {
+	return defaultStorage.Snapshot()
+}